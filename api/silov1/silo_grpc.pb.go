@@ -0,0 +1,225 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: silo.proto
+
+package silov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Silo_Scan_FullMethodName   = "/silo.v1.Silo/Scan"
+	Silo_Dump_FullMethodName   = "/silo.v1.Silo/Dump"
+	Silo_Lookup_FullMethodName = "/silo.v1.Silo/Lookup"
+)
+
+// SiloClient is the client API for Silo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Silo exposes a silo database, values are JSON values encoded the same way as in the CLI outputs.
+type SiloClient interface {
+	// Scan ingests a stream of data rows and returns the scan summary. The request metadata key "source" names the
+	// scanned input.
+	Scan(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[DataRow, ScanSummary], error)
+	// Dump streams the entities, with a progress message every DumpRequest.progress entities (if set) and always at
+	// the end of the stream.
+	Dump(ctx context.Context, in *DumpRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DumpResponse], error)
+	// Lookup returns the entity containing the node, or the NOT_FOUND status code.
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*Entity, error)
+}
+
+type siloClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSiloClient(cc grpc.ClientConnInterface) SiloClient {
+	return &siloClient{cc}
+}
+
+func (c *siloClient) Scan(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[DataRow, ScanSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Silo_ServiceDesc.Streams[0], Silo_Scan_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DataRow, ScanSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Silo_ScanClient = grpc.ClientStreamingClient[DataRow, ScanSummary]
+
+func (c *siloClient) Dump(ctx context.Context, in *DumpRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DumpResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Silo_ServiceDesc.Streams[1], Silo_Dump_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DumpRequest, DumpResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Silo_DumpClient = grpc.ServerStreamingClient[DumpResponse]
+
+func (c *siloClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*Entity, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entity)
+	err := c.cc.Invoke(ctx, Silo_Lookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SiloServer is the server API for Silo service.
+// All implementations must embed UnimplementedSiloServer
+// for forward compatibility.
+//
+// Silo exposes a silo database, values are JSON values encoded the same way as in the CLI outputs.
+type SiloServer interface {
+	// Scan ingests a stream of data rows and returns the scan summary. The request metadata key "source" names the
+	// scanned input.
+	Scan(grpc.ClientStreamingServer[DataRow, ScanSummary]) error
+	// Dump streams the entities, with a progress message every DumpRequest.progress entities (if set) and always at
+	// the end of the stream.
+	Dump(*DumpRequest, grpc.ServerStreamingServer[DumpResponse]) error
+	// Lookup returns the entity containing the node, or the NOT_FOUND status code.
+	Lookup(context.Context, *LookupRequest) (*Entity, error)
+	mustEmbedUnimplementedSiloServer()
+}
+
+// UnimplementedSiloServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSiloServer struct{}
+
+func (UnimplementedSiloServer) Scan(grpc.ClientStreamingServer[DataRow, ScanSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedSiloServer) Dump(*DumpRequest, grpc.ServerStreamingServer[DumpResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Dump not implemented")
+}
+func (UnimplementedSiloServer) Lookup(context.Context, *LookupRequest) (*Entity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedSiloServer) mustEmbedUnimplementedSiloServer() {}
+func (UnimplementedSiloServer) testEmbeddedByValue()              {}
+
+// UnsafeSiloServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SiloServer will
+// result in compilation errors.
+type UnsafeSiloServer interface {
+	mustEmbedUnimplementedSiloServer()
+}
+
+func RegisterSiloServer(s grpc.ServiceRegistrar, srv SiloServer) {
+	// If the following call pancis, it indicates UnimplementedSiloServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Silo_ServiceDesc, srv)
+}
+
+func _Silo_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SiloServer).Scan(&grpc.GenericServerStream[DataRow, ScanSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Silo_ScanServer = grpc.ClientStreamingServer[DataRow, ScanSummary]
+
+func _Silo_Dump_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DumpRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SiloServer).Dump(m, &grpc.GenericServerStream[DumpRequest, DumpResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Silo_DumpServer = grpc.ServerStreamingServer[DumpResponse]
+
+func _Silo_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SiloServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Silo_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SiloServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Silo_ServiceDesc is the grpc.ServiceDesc for Silo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Silo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "silo.v1.Silo",
+	HandlerType: (*SiloServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _Silo_Lookup_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _Silo_Scan_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Dump",
+			Handler:       _Silo_Dump_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "silo.proto",
+}
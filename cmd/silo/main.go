@@ -18,10 +18,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
 
 	"github.com/cgi-fr/silo/internal/app/cli"
 	"github.com/mattn/go-isatty"
@@ -92,17 +95,45 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&profiling, "profiling", "",
 		"create a pprof file - use 'cpu' to create a CPU pprof file or 'mem' to create an memory pprof file")
 
-	scanCmd := cli.NewScanCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	scanCmd := cli.NewScanCommand(name, version, os.Stderr, os.Stdout, os.Stdin)
 	dumpCmd := cli.NewDumpCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	serveCmd := cli.NewServeCommand(name, version, os.Stderr, os.Stdout, os.Stdin)
+	deleteCmd := cli.NewDeleteCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	retractCmd := cli.NewRetractCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	mergeCmd := cli.NewMergeCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	exportCmd := cli.NewExportCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	importCmd := cli.NewImportCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	infoCmd := cli.NewInfoCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	compactCmd := cli.NewCompactCommand(name, os.Stderr, os.Stdout, os.Stdin)
+	checkCmd := cli.NewCheckCommand(name, os.Stderr, os.Stdout, os.Stdin)
 
 	rootCmd.AddGroup(&cobra.Group{ID: "main", Title: "Main Commands:"})
+	rootCmd.AddGroup(&cobra.Group{ID: "maintenance", Title: "Maintenance Commands:"})
 
 	scanCmd.GroupID = "main"
 	dumpCmd.GroupID = "main"
-
-	rootCmd.AddCommand(scanCmd, dumpCmd)
-
-	if err := rootCmd.Execute(); err != nil {
+	serveCmd.GroupID = "main"
+	deleteCmd.GroupID = "maintenance"
+	retractCmd.GroupID = "maintenance"
+	mergeCmd.GroupID = "maintenance"
+	exportCmd.GroupID = "maintenance"
+	importCmd.GroupID = "maintenance"
+	infoCmd.GroupID = "maintenance"
+	compactCmd.GroupID = "maintenance"
+	checkCmd.GroupID = "maintenance"
+
+	rootCmd.AddCommand(scanCmd, dumpCmd, serveCmd, deleteCmd, retractCmd, mergeCmd, exportCmd, importCmd, infoCmd,
+		compactCmd, checkCmd)
+
+	// the first signal cancels the context so commands can stop cleanly, the next one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Err(err).Msg("error when executing command")
 		os.Exit(1)
 	}
@@ -0,0 +1,48 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/spf13/cobra"
+)
+
+// addBackendFlag adds the --backend flag, which selects the storage of silos given as a plain path.
+func addBackendFlag(cmd *cobra.Command, scheme *string) {
+	cmd.Flags().StringVar(scheme, "backend", infra.DefaultScheme,
+		"storage of silos given as a plain path ("+strings.Join(infra.Schemes(), ", ")+
+			"), silos can also be given as URIs such as pebble://path")
+}
+
+// openBackend opens the silo at location, a plain path or an URI such as pebble://path.
+func openBackend(location string, scheme string, readOnly bool) (infra.Storage, error) { //nolint:ireturn
+	uri, err := infra.ParseURI(location, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	backend, err := infra.Open(uri, readOnly)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return backend, nil
+}
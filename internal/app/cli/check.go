@@ -0,0 +1,115 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// ExitInconsistent is the exit code of the check command when issues are left in the silo.
+const ExitInconsistent = 2
+
+func NewCheckCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		repair     bool
+		jsonOutput bool
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:     "check path",
+		Short:   "Check the integrity of silo database stored in given path",
+		Example: "  " + parent + " check clients && " + parent + " dump clients",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			report, err := check(args[0], scheme, repair, cmd.OutOrStdout(), jsonOutput)
+			if err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+
+			if unrepaired := report.Unrepaired(); unrepaired > 0 {
+				log.Error().Int("issues", unrepaired).Int("return", ExitInconsistent).Msg("end SILO")
+				os.Exit(ExitInconsistent)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&repair, "repair", false, "store the missing direction of asymmetric links")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the report in JSON format")
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func check(path string, scheme string, repair bool, output io.Writer, jsonOutput bool) (infra.CheckReport, error) {
+	backend, err := openBackend(path, scheme, !repair)
+	if err != nil {
+		return infra.CheckReport{}, fmt.Errorf("%w", err) //nolint:exhaustruct
+	}
+
+	defer backend.Close()
+
+	report, err := infra.Check(backend, repair)
+	if err != nil {
+		return report, fmt.Errorf("unable to check %v : %w", path, err)
+	}
+
+	if jsonOutput {
+		if err := json.NewEncoder(output).Encode(report); err != nil {
+			return report, fmt.Errorf("%w", err)
+		}
+
+		return report, nil
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0) //nolint:gomnd
+
+	for _, issue := range report.Issues {
+		status := ""
+		if issue.Repaired {
+			status = " (repaired)"
+		} else if issue.Info {
+			status = " (information)"
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s%s\n", issue.Kind, issue.Node, issue.Detail, status)
+	}
+
+	fmt.Fprintf(writer, "nodes\t%d\n", report.Nodes)
+	fmt.Fprintf(writer, "links (both directions)\t%d\n", report.Links)
+	fmt.Fprintf(writer, "issues\t%d\n", report.Inconsistencies())
+	fmt.Fprintf(writer, "repaired\t%d\n", report.Inconsistencies()-report.Unrepaired())
+
+	if err := writer.Flush(); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/dustin/go-humanize"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewCompactCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		jsonOutput bool
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:     "compact path",
+		Short:   "Reclaim the space of obsolete data in silo database stored in given path",
+		Example: "  " + parent + " compact clients",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := compact(args[0], scheme, cmd.OutOrStdout(), jsonOutput); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the report in JSON format")
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func compact(path string, scheme string, output io.Writer, jsonOutput bool) error {
+	uri, err := infra.ParseURI(path, scheme)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// the sizes are measured on disk before opening and after closing the silo, when it is stored in files
+	before, errBefore := infra.DiskUsage(uri.Location)
+
+	report, err := compactBackend(uri)
+	if err != nil {
+		return fmt.Errorf("unable to compact %v : %w", path, err)
+	}
+
+	if after, errAfter := infra.DiskUsage(uri.Location); errBefore == nil && errAfter == nil {
+		report.SizeBefore, report.SizeAfter = before, after
+	}
+
+	log.Info().Str("path", path).Uint64("reclaimed", report.Reclaimed()).Msg("silo compacted")
+
+	if jsonOutput {
+		if err := json.NewEncoder(output).Encode(report); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0) //nolint:gomnd
+
+	fmt.Fprintf(writer, "nodes\t%d\n", report.Nodes)
+	fmt.Fprintf(writer, "self references dropped\t%d\n", report.SelfReferences)
+	fmt.Fprintf(writer, "size before\t%s\n", humanize.IBytes(report.SizeBefore))
+	fmt.Fprintf(writer, "size after\t%s\n", humanize.IBytes(report.SizeAfter))
+	fmt.Fprintf(writer, "reclaimed\t%s\n", humanize.IBytes(report.Reclaimed()))
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func compactBackend(uri infra.URI) (infra.CompactReport, error) {
+	backend, err := infra.Open(uri, false)
+	if err != nil {
+		return infra.CompactReport{}, fmt.Errorf("%w", err) //nolint:exhaustruct
+	}
+
+	report, err := infra.Compact(backend)
+	if err != nil {
+		backend.Close()
+
+		return report, fmt.Errorf("%w", err)
+	}
+
+	if err := backend.Close(); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	// pebble keeps the manifest written before the compaction as the previous
+	// manifest, it still holds the bounds of the compacted tables, two openings
+	// rotate it out so no value of a deleted node stays on disk
+	for i := 0; i < 2; i++ {
+		backend, err := infra.Open(uri, false)
+		if err != nil {
+			return report, fmt.Errorf("%w", err)
+		}
+
+		if err := backend.Close(); err != nil {
+			return report, fmt.Errorf("%w", err)
+		}
+	}
+
+	return report, nil
+}
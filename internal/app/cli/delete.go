@@ -0,0 +1,158 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewDeleteCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		composites map[string]string
+		raw        bool
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:   "delete path [key=value...]",
+		Short: "Delete values and all their links from silo database stored in given path",
+		Long: "Delete values and all their links from silo database stored in given path.\n" +
+			"Values are read from arguments, or from stdin in JSONLine format if no value is given as argument.",
+		Example: "  " + parent + " delete clients EMAIL_CLIENT=jonh.doe@domain.com\n" +
+			"  " + parent + " delete clients < erasure-requests.jsonl\n" +
+			"  " + parent + " delete clients --composite BRANCH_ACCOUNT=BRANCH+ACCOUNT " +
+			"'BRANCH_ACCOUNT={\"BRANCH\":\"001\",\"ACCOUNT\":\"12345\"}'",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := deleteNodes(args[0], scheme, args[1:], parseComposites(composites), raw); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().StringToStringVar(&composites, "composite", map[string]string{},
+		"composite keys declared on scan, as key=column1+column2, values of these keys are JSON objects of the columns")
+	cmd.Flags().BoolVar(&raw, "raw", false, "take values given as arguments as strings, without parsing them as JSON")
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+var (
+	ErrInvalidNode      = errors.New("invalid value, expected key=value")
+	ErrInvalidComposite = errors.New("invalid composite value, expected a JSON object with all the columns")
+)
+
+func deleteNodes(path string, scheme string, args []string, composites map[string][]string, raw bool) error {
+	nodes := make([]silo.DataNode, 0, len(args))
+
+	for _, arg := range args {
+		node, err := parseNode(arg, composites, raw)
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	backend, err := openBackend(path, scheme, false)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	options := make([]silo.Option, 0, len(composites))
+	for key, parts := range composites {
+		options = append(options, silo.WithComposite(key, parts))
+	}
+
+	driver := silo.NewDriver(backend, nil, options...)
+
+	if len(nodes) > 0 {
+		if err := driver.Delete(nodes...); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	reader, err := infra.NewDataRowReaderJSONLine()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := driver.DeleteAll(reader); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// parseNode reads a key=value argument, value is parsed as JSON if possible (e.g. 1 is a number, "1" is a string),
+// unless values are raw. The value of a composite key is a JSON object of its columns, encoded like the scan does.
+func parseNode(arg string, composites map[string][]string, raw bool) (silo.DataNode, error) {
+	key, text, found := strings.Cut(arg, "=")
+	if !found || key == "" {
+		return silo.DataNode{}, fmt.Errorf("%w '%s'", ErrInvalidNode, arg)
+	}
+
+	if parts, composite := composites[key]; composite {
+		var columns silo.DataRow
+		if err := json.Unmarshal([]byte(text), &columns); err != nil {
+			return silo.DataNode{}, fmt.Errorf("%w '%s' : %w", ErrInvalidComposite, arg, err)
+		}
+
+		value, complete := silo.NewCompositeValue(columns, parts)
+		if !complete {
+			return silo.DataNode{}, fmt.Errorf("%w '%s'", ErrInvalidComposite, arg)
+		}
+
+		return silo.DataNode{Key: key, Data: value}, nil
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); raw || err != nil || value == nil {
+		value = text
+	}
+
+	return silo.DataNode{Key: key, Data: value}, nil
+}
+
+// parseComposites reads the composite keys given as key=column1+column2.
+func parseComposites(composites map[string]string) map[string][]string {
+	result := make(map[string][]string, len(composites))
+	for key, parts := range composites {
+		result[key] = strings.Split(parts, "+")
+	}
+
+	return result
+}
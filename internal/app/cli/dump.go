@@ -18,8 +18,13 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cgi-fr/silo/internal/infra"
 	"github.com/cgi-fr/silo/pkg/silo"
@@ -29,9 +34,22 @@
 
 func NewDumpCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
 	var (
-		include    []string
-		watch      bool
-		limitedRAM bool
+		include     []string
+		mandatory   []string
+		optional    []string
+		cardinality map[string]string
+		rules       []string
+		policies    map[string]string
+		statuses    []string
+		withStatus  bool
+		noSoftLinks bool
+		watch       bool
+		limitedRAM  bool
+		budget      string
+		strategy    string
+		changed     bool
+		scheme      string
+		timeout     time.Duration
 	)
 
 	cmd := &cobra.Command{ //nolint:exhaustruct
@@ -39,16 +57,62 @@ func NewDumpCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.F
 		Short:   "Dump silo database stored in given path into stdout",
 		Example: "  " + parent + " dump clients",
 		Args:    cobra.ExactArgs(1),
-		Run: func(_ *cobra.Command, args []string) {
-			if err := dump(args[0], include, watch, limitedRAM); err != nil {
+		Run: func(cmd *cobra.Command, args []string) {
+			options, err := dumpOptions(include, mandatory, optional, cardinality, rules, statuses)
+			if err != nil {
 				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
 			}
+
+			options = append(options, silo.WithSoftLinks(!noSoftLinks))
+
+			for key, policy := range policies {
+				options = append(options, silo.WithMergePolicy(key, silo.MergePolicy(policy)))
+			}
+
+			if limitedRAM {
+				log.Warn().Msg("flag --limited-ram is deprecated, use --memory-budget instead")
+			}
+
+			ctx, cancel := withTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			strategy = dumpStrategy(strategy, limitedRAM, budget, changed)
+
+			if err := dump(ctx, args[0], scheme, options, withStatus, watch, strategy, budget, changed); err != nil {
+				cancel()
+				fatal(err)
+			}
 		},
 	}
 
 	cmd.Flags().StringSliceVarP(&include, "include", "i", []string{}, "include only these columns, exclude all others")
+	cmd.Flags().StringSliceVarP(&mandatory, "mandatory", "m", []string{},
+		"keys required for an entity to be complete, these keys are also included")
+	cmd.Flags().StringSliceVarP(&optional, "optional", "o", []string{},
+		"keys not required for an entity to be complete, these keys are also included")
+	cmd.Flags().StringToStringVar(&cardinality, "count", map[string]string{},
+		"expected count of distinct values for a key in an entity, as key=min:max (use * as max for unbounded)")
+	cmd.Flags().StringArrayVar(&rules, "rule", []string{},
+		"custom status given to entities satisfying all constraints, as status=key:min:max,key:min:max...")
+	cmd.Flags().StringToStringVar(&policies, "merge", map[string]string{},
+		"merge policy of attributes values, as attribute=policy (policy : first, last, all or max, default is all)")
+	cmd.Flags().StringSliceVarP(&statuses, "status", "s", []string{},
+		"dump only entities with one of these statuses : complete, consistent, inconsistent or empty")
+	cmd.Flags().BoolVar(&withStatus, "with-status", false, "add entity status and per-key counts to each dumped line")
+	cmd.Flags().BoolVar(&noSoftLinks, "no-soft-links", false, "do not follow soft links found by fuzzy matching")
 	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "watch statistics about dumped entities in stderr")
-	cmd.Flags().BoolVar(&limitedRAM, "limited-ram", false, "limit RAM usage, slower but more efficient on RAM usage")
+	cmd.Flags().BoolVar(&limitedRAM, "limited-ram", false, "deprecated, use --memory-budget instead")
+	cmd.Flags().StringVar(&strategy, "strategy", "auto",
+		"dump strategy of pebble and sharded silos : auto, full, iterate-once or spill, "+
+			"auto chooses from the silo size")
+	cmd.Flags().StringVar(&budget, "memory-budget", "",
+		"bound the memory used to track visited nodes (e.g. 512MiB), beyond it they are spilled to a temporary directory")
+	cmd.Flags().BoolVar(&changed, "changed", false,
+		"dump only entities changed since the last dump of changes, with the ids of the entities they replace")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0,
+		"stop the dump after this duration (e.g. 30s, 10m), default is no timeout")
+
+	addBackendFlag(cmd, &scheme)
 
 	cmd.Flags().SortFlags = false
 
@@ -59,36 +123,170 @@ func NewDumpCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.F
 	return cmd
 }
 
-func dump(path string, include []string, watch bool, limitedRAM bool) error {
-	var (
-		backend silo.Backend
-		err     error
-	)
+func dump(
+	ctx context.Context,
+	path string,
+	scheme string,
+	options []silo.Option,
+	withStatus bool,
+	watch bool,
+	strategy string,
+	budget string,
+	changed bool,
+) error {
+	uri, err := infra.ParseURI(path, scheme)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// a strategy given in the URI overrides the flags
+	if (uri.Scheme == "pebble" || uri.Scheme == "sharded") && !uri.Params.Has("snapshot") {
+		uri.Params.Set("snapshot", strategy)
 
-	if limitedRAM {
-		backend, err = infra.NewBackend(path)
-	} else {
-		backend, err = infra.NewBackendFull(path)
+		if budget != "" {
+			uri.Params.Set("budget", budget)
+		}
+	} else if budget != "" {
+		log.Warn().Str("backend", uri.String()).Msg("memory budget ignored, it applies to pebble and sharded silos only")
 	}
 
+	backend, err := infra.Open(uri, false)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	defer backend.Close()
 
-	driver := silo.NewDriver(backend, infra.NewDumpJSONLine(), silo.WithKeys(include))
+	writer := infra.NewDumpJSONLine()
+	if withStatus {
+		writer = infra.NewDumpJSONLineWithStatus()
+	}
+
+	driver := silo.NewDriver(backend, writer, options...)
+
+	run := driver.DumpContext
+	if changed {
+		run = driver.DumpChanged
+	}
+
+	observers := []silo.DumpObserver{}
 
 	if watch {
 		observer := infra.NewDumpObserver()
 		defer observer.Close()
 
-		if err := driver.Dump(observer); err != nil {
-			return fmt.Errorf("%w", err)
-		}
-	} else if err := driver.Dump(); err != nil {
+		observers = append(observers, observer)
+	}
+
+	if err := run(ctx, observers...); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	return nil
 }
+
+// dumpStrategy returns the strategy used when the default auto strategy is not overridden : a memory budget or the
+// deprecated limited RAM flag select the spill strategy, and a dump of changes reads few entities so it does not need
+// to load the silo.
+func dumpStrategy(strategy string, limitedRAM bool, budget string, changed bool) string {
+	switch {
+	case strategy != "auto":
+		return strategy
+	case budget != "" || limitedRAM:
+		return "spill"
+	case changed:
+		return "iterate-once"
+	default:
+		return "auto"
+	}
+}
+
+var ErrInvalidConstraint = errors.New("invalid constraint")
+
+func dumpOptions(
+	include, mandatory, optional []string,
+	cardinality map[string]string,
+	rules, statuses []string,
+) ([]silo.Option, error) {
+	options := []silo.Option{
+		silo.WithKeys(include),
+		silo.WithMandatoryKeys(mandatory),
+		silo.WithOptionalKeys(optional),
+	}
+
+	for key, value := range cardinality {
+		constraint, err := parseConstraint(key + ":" + value)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, silo.WithCardinality(key, constraint.Min, constraint.Max))
+	}
+
+	for _, rule := range rules {
+		status, definition, found := strings.Cut(rule, "=")
+		if !found || status == "" {
+			return nil, fmt.Errorf("%w '%s'", ErrInvalidConstraint, rule)
+		}
+
+		constraints := []silo.Constraint{}
+
+		for _, item := range strings.Split(definition, ",") {
+			constraint, err := parseConstraint(item)
+			if err != nil {
+				return nil, err
+			}
+
+			constraints = append(constraints, constraint)
+		}
+
+		options = append(options, silo.WithRule(silo.Status(status), constraints...))
+	}
+
+	selected := make([]silo.Status, 0, len(statuses))
+	for _, status := range statuses {
+		selected = append(selected, silo.Status(status))
+	}
+
+	return append(options, silo.WithStatuses(selected)), nil
+}
+
+// parseConstraint reads a constraint formatted as key:min:max, key:count or key (exactly one value).
+func parseConstraint(definition string) (silo.Constraint, error) {
+	parts := strings.Split(definition, ":")
+	constraint := silo.Constraint{Key: parts[0], Cardinality: silo.Cardinality{Min: 1, Max: 1}}
+
+	var err error
+
+	switch len(parts) {
+	case 1:
+	case 2: //nolint:gomnd
+		constraint.Min, err = strconv.Atoi(parts[1])
+		constraint.Max = constraint.Min
+	case 3: //nolint:gomnd
+		if constraint.Min, err = strconv.Atoi(parts[1]); err == nil {
+			constraint.Max, err = parseMax(parts[2])
+		}
+	default:
+		err = ErrInvalidConstraint
+	}
+
+	if err != nil || constraint.Key == "" {
+		return constraint, fmt.Errorf("%w '%s'", ErrInvalidConstraint, definition)
+	}
+
+	return constraint, nil
+}
+
+func parseMax(value string) (int, error) {
+	if value == "*" {
+		return silo.Unbounded, nil
+	}
+
+	max, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return max, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Exit codes of commands stopped before the end of their input.
+const (
+	ExitTimeout     = 124
+	ExitInterrupted = 130
+)
+
+// fatal logs err and exits, the silo is already closed so it can be used again (see scan --resume).
+func fatal(err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Error().Err(err).Int("return", ExitTimeout).Msg("end SILO")
+		os.Exit(ExitTimeout)
+	case errors.Is(err, context.Canceled):
+		log.Warn().Err(err).Int("return", ExitInterrupted).Msg("end SILO")
+		os.Exit(ExitInterrupted)
+	default:
+		log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+	}
+}
+
+// withTimeout returns a context done after timeout, a zero timeout means no timeout.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
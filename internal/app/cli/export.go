@@ -0,0 +1,70 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewExportCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var scheme string
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:     "export path",
+		Short:   "Export nodes and links of silo database stored in given path to stdout in a portable format",
+		Example: "  " + parent + " export clients > clients.silo.jsonl",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := export(args[0], scheme, cmd.OutOrStdout()); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func export(path string, scheme string, output io.Writer) error {
+	backend, err := openBackend(path, scheme, true)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	count, err := infra.Export(backend, output)
+	if err != nil {
+		return fmt.Errorf("unable to export %v : %w", path, err)
+	}
+
+	log.Info().Str("path", path).Int("nodes", count).Msg("silo exported")
+
+	return nil
+}
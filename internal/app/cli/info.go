@@ -0,0 +1,165 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewInfoCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		jsonOutput bool
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:     "info path",
+		Short:   "Print keys, counters and scan history of silo database stored in given path",
+		Example: "  " + parent + " info clients",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := info(args[0], scheme, cmd.OutOrStdout(), jsonOutput); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print information in JSON format")
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func info(path string, scheme string, output io.Writer, jsonOutput bool) error {
+	backend, err := openBackend(path, scheme, true)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	summary, err := infra.ReadInfo(backend)
+	if err != nil {
+		return fmt.Errorf("unable to read %v : %w", path, err)
+	}
+
+	scans := summary.Metadata.Scans
+	for index := range scans {
+		previous := silo.Metadata{Scans: scans[:index]}
+		if fields := previous.InconsistentAliases(scans[index]); len(fields) > 0 {
+			log.Warn().Strs("fields", fields).Int("scan", index+1).
+				Msg("aliases are inconsistent with a previous scan of this silo")
+		}
+	}
+
+	if jsonOutput {
+		if err := json.NewEncoder(output).Encode(summary); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	return printInfo(output, summary)
+}
+
+func printInfo(output io.Writer, summary infra.Info) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0) //nolint:gomnd
+
+	fmt.Fprintf(writer, "nodes\t%d\n", summary.Nodes)
+	fmt.Fprintf(writer, "links\t%d\n", summary.Links)
+	fmt.Fprintf(writer, "soft links\t%d\n", summary.SoftLinks)
+	fmt.Fprintf(writer, "attributes\t%d\n", summary.Attributes)
+	fmt.Fprintf(writer, "scans\t%d\n", len(summary.Metadata.Scans))
+
+	keys := make([]string, 0, len(summary.Keys))
+	for key := range summary.Keys {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	fmt.Fprintln(writer, "keys")
+
+	for _, key := range keys {
+		fmt.Fprintf(writer, "  %s\t%d\n", key, summary.Keys[key])
+	}
+
+	if last, ok := summary.Metadata.LastScan(); ok {
+		fmt.Fprintln(writer, "last scan")
+		fmt.Fprintf(writer, "  time\t%s\n", last.Time.Format(time.RFC3339))
+		fmt.Fprintf(writer, "  source\t%s\n", last.Source)
+		fmt.Fprintf(writer, "  version\t%s\n", last.Version)
+		fmt.Fprintf(writer, "  rows\t%d\n", last.Rows)
+		fmt.Fprintf(writer, "  links\t%d\n", last.Links)
+		fmt.Fprintf(writer, "  include\t%s\n", strings.Join(last.Include, ","))
+		fmt.Fprintf(writer, "  aliases\t%s\n", joinMap(last.Aliases))
+		fmt.Fprintf(writer, "  attributes\t%s\n", strings.Join(last.Attributes, ","))
+		fmt.Fprintf(writer, "  fuzzy\t%s\n", strings.Join(last.Fuzzy, ","))
+	}
+
+	sources := make([]string, 0, len(summary.Metadata.Checkpoints))
+	for source := range summary.Metadata.Checkpoints {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	if len(sources) > 0 {
+		fmt.Fprintln(writer, "checkpoints")
+	}
+
+	for _, source := range sources {
+		checkpoint := summary.Metadata.Checkpoints[source]
+		fmt.Fprintf(writer, "  %s\t%d rows (complete=%t)\n", source, checkpoint.Rows, checkpoint.Complete)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func joinMap(items map[string]string) string {
+	pairs := make([]string, 0, len(items))
+
+	for key, value := range items {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewMergeCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		aliases map[string]string
+		scheme  string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:   "merge target source...",
+		Short: "Merge links of silo databases stored in source paths into silo database stored in target path",
+		Example: "  " + parent + " merge clients clients-crm clients-billing --alias CUSTOMER_ID=ID_CLIENT\n" +
+			"  " + parent + " merge clients crm billing --alias crm:CUSTOMER_ID=ID --alias billing:CLIENT=ID",
+		Args: cobra.MinimumNArgs(2), //nolint:gomnd
+		Run: func(_ *cobra.Command, args []string) {
+			if err := merge(args[0], args[1:], scheme, aliases); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().StringToStringVarP(&aliases, "alias", "a", map[string]string{},
+		"rename keys of source silos on import, as FROM=TO for all sources or source:FROM=TO for a single source")
+	addBackendFlag(cmd, &scheme)
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func merge(target string, sources []string, scheme string, aliases map[string]string) error {
+	backend, err := openBackend(target, scheme, false)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	renames := sourceAliases(aliases, sources)
+
+	for _, path := range sources {
+		if err := mergeSource(backend, path, scheme, renames[path]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func mergeSource(backend infra.Storage, path string, scheme string, aliases map[string]string) error {
+	source, err := openBackend(path, scheme, true)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer source.Close()
+
+	count, err := infra.Merge(backend, source, aliases)
+	if err != nil {
+		return fmt.Errorf("unable to merge %v : %w", path, err)
+	}
+
+	log.Info().Str("source", path).Int("nodes", count).Msg("silo merged")
+
+	return nil
+}
+
+// sourceAliases returns the aliases of each source : an alias given as source:FROM=TO only renames the keys of this
+// source, and overrides an alias of the same key given as FROM=TO for all sources. The source is split at the last
+// ':' so it can be an URI, a prefix that is not one of the sources is part of the key.
+func sourceAliases(aliases map[string]string, sources []string) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(sources))
+	for _, source := range sources {
+		result[source] = map[string]string{}
+	}
+
+	global := map[string]string{}
+
+	for key, alias := range aliases {
+		if index := strings.LastIndex(key, ":"); index >= 0 {
+			if renames, ok := result[key[:index]]; ok {
+				renames[key[index+1:]] = alias
+
+				continue
+			}
+		}
+
+		global[key] = alias
+	}
+
+	for _, renames := range result {
+		for key, alias := range global {
+			if _, ok := renames[key]; !ok {
+				renames[key] = alias
+			}
+		}
+	}
+
+	return result
+}
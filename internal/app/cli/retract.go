@@ -0,0 +1,93 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func NewRetractCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		include    []string
+		aliases    map[string]string
+		composites map[string]string
+		attributes []string
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:   "retract path",
+		Short: "Remove links contributed by rows read from stdin from silo database stored in given path",
+		Long: "Remove links contributed by rows read from stdin from silo database stored in given path.\n" +
+			"Use the same options as the scan command that ingested the rows.",
+		Example: "  " + parent + " retract clients < clients-to-remove.jsonl",
+		Args:    cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			options, err := scanOptions(include, aliases, composites, attributes, map[string]string{})
+			if err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+
+			if err := retract(args[0], scheme, options); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&include, "include", "i", []string{}, "include only these columns, exclude all others")
+	cmd.Flags().StringToStringVarP(&aliases, "alias", "a", map[string]string{}, "use given aliases for each columns")
+	cmd.Flags().StringToStringVar(&composites, "composite", map[string]string{},
+		"combine several columns into a single key, as key=column1+column2")
+	cmd.Flags().StringSliceVar(&attributes, "attribute", []string{}, "columns stored as attributes of the entity")
+
+	addBackendFlag(cmd, &scheme)
+
+	cmd.Flags().SortFlags = false
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+func retract(path string, scheme string, options []silo.Option) error {
+	backend, err := openBackend(path, scheme, false)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	reader, err := infra.NewDataRowReaderJSONLine()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := silo.NewDriver(backend, nil, options...).Retract(reader); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
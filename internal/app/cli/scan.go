@@ -18,20 +18,34 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/fuzzy"
 	"github.com/cgi-fr/silo/pkg/silo"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
-func NewScanCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+func NewScanCommand(parent string, version string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
 	var (
+		source      string
 		passthrough bool
 		include     []string
 		aliases     map[string]string
+		fuzzyKeys   map[string]string
+		composites  map[string]string
+		attributes  []string
+		timeout     time.Duration
+		resume      bool
+		checkpoint  int
+		scheme      string
 	)
 
 	cmd := &cobra.Command{ //nolint:exhaustruct
@@ -40,15 +54,52 @@ func NewScanCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.F
 		Example: "  " + parent + " scan clients < clients.jsonl",
 		Args:    cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := scan(cmd, args[0], passthrough, include, aliases); err != nil {
+			// the default source is shared by all inputs, its checkpoint does not identify the input to resume
+			if resume && !cmd.Flags().Changed("source") {
+				log.Fatal().Err(ErrResumeWithoutSource).Int("return", 1).Msg("end SILO")
+			}
+
+			options, err := scanOptions(include, aliases, composites, attributes, fuzzyKeys)
+			if err != nil {
 				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
 			}
+
+			options = append(options,
+				silo.WithSource(source),
+				silo.WithVersion(version),
+				silo.WithCheckpoint(checkpoint),
+				silo.WithResume(resume),
+			)
+
+			ctx, cancel := withTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			if err := scan(ctx, cmd, args[0], scheme, passthrough, options); err != nil {
+				cancel()
+				fatal(err)
+			}
 		},
 	}
 
 	cmd.Flags().BoolVarP(&passthrough, "passthrough", "p", false, "pass stdin to stdout")
 	cmd.Flags().StringSliceVarP(&include, "include", "i", []string{}, "include only these columns, exclude all others")
 	cmd.Flags().StringToStringVarP(&aliases, "alias", "a", map[string]string{}, "use given aliases for each columns")
+	cmd.Flags().StringToStringVar(&composites, "composite", map[string]string{},
+		"combine several columns into a single key, as key=column1+column2, combined columns are not linked on their own")
+	cmd.Flags().StringSliceVar(&attributes, "attribute", []string{},
+		"store these columns as attributes of the entity, attributes are dumped but never create links")
+	cmd.Flags().StringToStringVar(&fuzzyKeys, "fuzzy", map[string]string{},
+		"add soft links between similar values of a column, as column=blocking:similarity:threshold "+
+			"(blocking : soundex or ngram, similarity : jaro-winkler, jaro or levenshtein)")
+	cmd.Flags().StringVar(&source, "source", "stdin", "name of the scanned input, recorded in the silo metadata")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0,
+		"stop the scan after this duration (e.g. 30s, 10m), default is no timeout")
+	cmd.Flags().BoolVar(&resume, "resume", false,
+		"skip rows of the source already ingested according to its last checkpoint, requires --source")
+	cmd.Flags().IntVar(&checkpoint, "checkpoint", silo.DefaultCheckpointInterval,
+		"number of rows between two checkpoints of the source, 0 to disable")
+
+	addBackendFlag(cmd, &scheme)
 
 	cmd.Flags().SortFlags = false
 
@@ -59,15 +110,17 @@ func NewScanCommand(parent string, stderr *os.File, stdout *os.File, stdin *os.F
 	return cmd
 }
 
-func scan(cmd *cobra.Command, path string, passthrough bool, include []string, aliases map[string]string) error {
-	backend, err := infra.NewBackend(path)
+func scan(
+	ctx context.Context, cmd *cobra.Command, path string, scheme string, passthrough bool, options []silo.Option,
+) error {
+	backend, err := openBackend(path, scheme, false)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	defer backend.Close()
 
-	driver := silo.NewDriver(backend, nil, silo.WithKeys(include), silo.WithAliases(aliases))
+	driver := silo.NewDriver(backend, nil, options...)
 
 	var reader silo.DataRowReader
 
@@ -85,12 +138,74 @@ func scan(cmd *cobra.Command, path string, passthrough bool, include []string, a
 		observer := infra.NewScanObserver()
 		defer observer.Close()
 
-		if err := driver.Scan(reader, observer); err != nil {
+		if err := driver.ScanContext(ctx, reader, observer); err != nil {
 			return fmt.Errorf("%w", err)
 		}
-	} else if err := driver.Scan(reader); err != nil {
+	} else if err := driver.ScanContext(ctx, reader); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	return nil
 }
+
+var (
+	ErrInvalidFuzzyMatching = errors.New("invalid fuzzy matching")
+	ErrResumeWithoutSource  = errors.New("--resume requires the name of the scanned input given with --source")
+)
+
+const defaultNGramSize = 3
+
+func scanOptions(
+	include []string,
+	aliases map[string]string,
+	composites map[string]string,
+	attributes []string,
+	fuzzyKeys map[string]string,
+) ([]silo.Option, error) {
+	options := []silo.Option{silo.WithKeys(include), silo.WithAliases(aliases), silo.WithAttributes(attributes)}
+
+	for key, parts := range composites {
+		options = append(options, silo.WithComposite(key, strings.Split(parts, "+")))
+	}
+
+	for key, definition := range fuzzyKeys {
+		parts := strings.Split(definition, ":")
+		if len(parts) != 3 { //nolint:gomnd
+			return nil, fmt.Errorf("%w '%s=%s'", ErrInvalidFuzzyMatching, key, definition)
+		}
+
+		var (
+			blocking   func(string) []string
+			similarity func(string, string) float64
+		)
+
+		switch parts[0] {
+		case "soundex":
+			blocking = fuzzy.Soundex
+		case "ngram":
+			blocking = fuzzy.NGrams(defaultNGramSize)
+		default:
+			return nil, fmt.Errorf("%w : unknown blocking '%s'", ErrInvalidFuzzyMatching, parts[0])
+		}
+
+		switch parts[1] {
+		case "jaro-winkler":
+			similarity = fuzzy.JaroWinkler
+		case "jaro":
+			similarity = fuzzy.Jaro
+		case "levenshtein":
+			similarity = fuzzy.Levenshtein
+		default:
+			return nil, fmt.Errorf("%w : unknown similarity '%s'", ErrInvalidFuzzyMatching, parts[1])
+		}
+
+		threshold, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w : %w", ErrInvalidFuzzyMatching, err)
+		}
+
+		options = append(options, silo.WithFuzzyMatching(key, blocking, similarity, threshold))
+	}
+
+	return options, nil
+}
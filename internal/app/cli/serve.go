@@ -0,0 +1,173 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cgi-fr/silo/internal/app/grpcserver"
+	"github.com/cgi-fr/silo/internal/app/server"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 30 * time.Second
+)
+
+func NewServeCommand(parent string, version string, stderr *os.File, stdout *os.File, stdin *os.File) *cobra.Command {
+	var (
+		listen     string
+		protocol   string
+		include    []string
+		aliases    map[string]string
+		fuzzyKeys  map[string]string
+		composites map[string]string
+		attributes []string
+		scheme     string
+	)
+
+	cmd := &cobra.Command{ //nolint:exhaustruct
+		Use:     "serve path",
+		Short:   "Serve silo database stored in given path through an HTTP/JSON or a gRPC API",
+		Example: "  " + parent + " serve clients --listen localhost:8080",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			options, err := scanOptions(include, aliases, composites, attributes, fuzzyKeys)
+			if err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+
+			options = append(options, silo.WithVersion(version))
+
+			if err := serve(cmd.Context(), args[0], scheme, listen, protocol, options); err != nil {
+				log.Fatal().Err(err).Int("return", 1).Msg("end SILO")
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&listen, "listen", "l", "localhost:8080", "address to listen on")
+	cmd.Flags().StringVar(&protocol, "protocol", "http", "protocol of the API : http (JSON) or grpc")
+	cmd.Flags().StringSliceVarP(&include, "include", "i", []string{},
+		"include only these columns on scan, exclude all others")
+	cmd.Flags().StringToStringVarP(&aliases, "alias", "a", map[string]string{},
+		"use given aliases for each columns on scan")
+	cmd.Flags().StringToStringVar(&composites, "composite", map[string]string{},
+		"combine several columns into a single key on scan, as key=column1+column2")
+	cmd.Flags().StringSliceVar(&attributes, "attribute", []string{},
+		"store these columns as attributes of the entity on scan")
+	cmd.Flags().StringToStringVar(&fuzzyKeys, "fuzzy", map[string]string{},
+		"add soft links between similar values of a column on scan, as column=blocking:similarity:threshold")
+
+	addBackendFlag(cmd, &scheme)
+
+	cmd.Flags().SortFlags = false
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetIn(stdin)
+
+	return cmd
+}
+
+var ErrUnknownProtocol = errors.New("unknown protocol")
+
+// serve runs the server until ctx is done, then stops it gracefully.
+func serve(
+	ctx context.Context, path string, scheme string, listen string, protocol string, options []silo.Option,
+) error {
+	if protocol != "http" && protocol != "grpc" {
+		return fmt.Errorf("%w '%s'", ErrUnknownProtocol, protocol)
+	}
+
+	backend, err := openBackend(path, scheme, false)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer backend.Close()
+
+	// fail fast on invalid scan options
+	_ = silo.NewDriver(backend, nil, options...)
+
+	if protocol == "grpc" {
+		return serveGRPC(ctx, backend, path, listen, options)
+	}
+
+	httpServer := &http.Server{ //nolint:exhaustruct
+		Addr:              listen,
+		Handler:           server.NewServer(backend, options...).Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	log.Info().Str("listen", listen).Str("path", path).Msg("silo server started")
+
+	go func() {
+		<-ctx.Done()
+
+		log.Info().Msg("silo server stopping")
+
+		shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdown); err != nil {
+			log.Error().Err(err).Msg("silo server stopped")
+		}
+	}()
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func serveGRPC(ctx context.Context, backend silo.Backend, path string, listen string, options []silo.Option) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	grpcserver.NewServer(backend, options...).Register(grpcServer)
+
+	log.Info().Str("listen", listen).Str("path", path).Msg("silo gRPC server started")
+
+	go func() {
+		<-ctx.Done()
+
+		log.Info().Msg("silo gRPC server stopping")
+		grpcServer.GracefulStop()
+	}()
+
+	if err := grpcServer.Serve(listener); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cgi-fr/silo/api/silov1"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Server exposes a silo database through the gRPC service described in api/silo.proto, scans, lookups and dumps
+// run concurrently. Each call builds its own driver on the shared backend, a dump streams from a snapshot.
+type Server struct {
+	silov1.UnimplementedSiloServer
+
+	backend silo.Backend
+	options []silo.Option
+}
+
+// NewServer returns a server sharing backend between calls, options are applied to scans, lookups and dumps.
+func NewServer(backend silo.Backend, options ...silo.Option) *Server {
+	return &Server{
+		UnimplementedSiloServer: silov1.UnimplementedSiloServer{},
+		backend:                 backend,
+		options:                 options,
+	}
+}
+
+// Register adds the silo service to registrar.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	silov1.RegisterSiloServer(registrar, s)
+}
+
+func newEntity(summary silo.EntitySummary, nodes []silo.DataNode) (*silov1.Entity, error) {
+	result := &silov1.Entity{ //nolint:exhaustruct
+		Uuid:       summary.UUID,
+		Status:     string(summary.Status),
+		Counts:     make(map[string]int64, len(summary.Counts)),
+		Attributes: make(map[string]*structpb.Value, len(summary.Attributes)),
+		Nodes:      make([]*silov1.Node, 0, len(nodes)),
+	}
+
+	for key, count := range summary.Counts {
+		result.Counts[key] = int64(count)
+	}
+
+	for key, attribute := range summary.Attributes {
+		value, err := toValue(attribute)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Attributes[key] = value
+	}
+
+	for _, item := range nodes {
+		value, err := toValue(item.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Nodes = append(result.Nodes, &silov1.Node{Id: item.Key, Key: value}) //nolint:exhaustruct
+	}
+
+	return result, nil
+}
+
+// scanObserver counts rows and links ingested by a scan.
+type scanObserver struct {
+	rows  int64
+	links int64
+}
+
+func (o *scanObserver) IngestedRow(_ silo.DataRow) {
+	o.rows++
+}
+
+func (o *scanObserver) IngestedLink(_ silo.DataLink) {
+	o.links++
+}
+
+// streamReader reads data rows from a client stream.
+type streamReader struct {
+	stream silov1.Silo_ScanServer
+}
+
+func (r streamReader) ReadDataRow() (silo.DataRow, error) {
+	row, err := r.stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	result := make(silo.DataRow, len(row.GetFields()))
+	for key, value := range row.GetFields() {
+		result[key] = value.AsInterface()
+	}
+
+	return result, nil
+}
+
+func (r streamReader) Close() error {
+	return nil
+}
+
+func (s *Server) Scan(stream silov1.Silo_ScanServer) error {
+	options := append([]silo.Option{silo.WithSource(source(stream.Context()))}, s.options...)
+	observer := &scanObserver{rows: 0, links: 0}
+
+	driver := silo.NewDriver(s.backend, nil, options...)
+
+	if err := driver.ScanContext(stream.Context(), streamReader{stream: stream}, observer); err != nil {
+		return toStatus(codes.InvalidArgument, err)
+	}
+
+	return stream.SendAndClose(&silov1.ScanSummary{Rows: observer.rows, Links: observer.links}) //nolint:exhaustruct
+}
+
+// dumpStream buffers the nodes written by the driver and sends them as a single entity message when
+// the entity is observed, with a progress message every given number of entities.
+type dumpStream struct {
+	stream   silov1.Silo_DumpServer
+	nodes    []silo.DataNode
+	progress *silov1.Progress
+	every    int64
+	err      error
+}
+
+func (d *dumpStream) Write(node silo.DataNode, _ silo.EntitySummary) error {
+	if d.err != nil {
+		return d.err
+	}
+
+	d.nodes = append(d.nodes, node)
+
+	return nil
+}
+
+func (d *dumpStream) Close() error {
+	return nil
+}
+
+func (d *dumpStream) Entity(summary silo.EntitySummary) {
+	if d.err != nil {
+		return
+	}
+
+	entity, err := newEntity(summary, d.nodes)
+	if err != nil {
+		d.err = err
+
+		return
+	}
+
+	d.err = d.stream.Send(&silov1.DumpResponse{Message: &silov1.DumpResponse_Entity{Entity: entity}}) //nolint:exhaustruct
+	d.nodes = d.nodes[:0]
+
+	d.progress.Entities++
+	d.progress.Statuses[string(summary.Status)]++
+
+	if d.err == nil && d.every > 0 && d.progress.GetEntities()%d.every == 0 {
+		d.err = d.sendProgress()
+	}
+}
+
+func (d *dumpStream) sendProgress() error {
+	return d.stream.Send(&silov1.DumpResponse{ //nolint:exhaustruct
+		Message: &silov1.DumpResponse_Progress{Progress: d.progress},
+	})
+}
+
+func (s *Server) Dump(request *silov1.DumpRequest, stream silov1.Silo_DumpServer) error {
+	statuses := make([]silo.Status, 0, len(request.GetStatuses()))
+	for _, value := range request.GetStatuses() {
+		statuses = append(statuses, silo.Status(value))
+	}
+
+	writer := &dumpStream{
+		stream:   stream,
+		nodes:    []silo.DataNode{},
+		progress: &silov1.Progress{Entities: 0, Statuses: map[string]int64{}}, //nolint:exhaustruct
+		every:    request.GetProgress(),
+		err:      nil,
+	}
+
+	options := append([]silo.Option{silo.WithStatuses(statuses)}, s.options...)
+
+	driver, err := newDriver(s.backend, writer, options...)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck
+	}
+
+	if err := driver.DumpContext(stream.Context(), writer); err != nil {
+		return toStatus(codes.Internal, err)
+	}
+
+	if writer.err != nil {
+		return writer.err
+	}
+
+	return writer.sendProgress()
+}
+
+func (s *Server) Lookup(_ context.Context, request *silov1.LookupRequest) (*silov1.Entity, error) {
+	key := silo.DataNode{Key: request.GetNode().GetId(), Data: request.GetNode().GetKey().AsInterface()}
+
+	driver := silo.NewDriver(s.backend, nil, s.options...)
+
+	summary, nodes, found, err := driver.Lookup(key)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck
+	}
+
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "node not found : %v=%v", key.Key, key.Data) //nolint:wrapcheck
+	}
+
+	return newEntity(summary, nodes)
+}
+
+// newDriver recovers the configuration errors reported by silo.NewDriver.
+func newDriver(backend silo.Backend, writer silo.DumpWriter, options ...silo.Option) (driver *silo.Driver, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if errs, ok := r.([]error); ok {
+				err = errors.Join(errs...)
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	return silo.NewDriver(backend, writer, options...), nil
+}
+
+// toStatus converts err to a gRPC status, an interrupted call gets the status of its context error.
+func toStatus(code codes.Code, err error) error {
+	if errors.Is(err, silo.ErrInterrupted) {
+		return status.FromContextError(err).Err() //nolint:wrapcheck
+	}
+
+	return status.Error(code, err.Error()) //nolint:wrapcheck
+}
+
+// toValue converts data to a protobuf value through its JSON representation, so values are encoded
+// the same way as in the other outputs of SILO (e.g. composite values are JSON objects).
+func toValue(data any) (*structpb.Value, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck
+	}
+
+	result := new(structpb.Value)
+	if err := protojson.Unmarshal(raw, result); err != nil {
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck
+	}
+
+	return result, nil
+}
+
+func source(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, "source"); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+
+	return "grpc"
+}
@@ -0,0 +1,276 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package grpcserver_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgi-fr/silo/api/silov1"
+	"github.com/cgi-fr/silo/internal/app/grpcserver"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func connect(t *testing.T, backend silo.Backend, options ...silo.Option) silov1.SiloClient { //nolint:ireturn
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+
+	grpcserver.NewServer(backend, options...).Register(server)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return silov1.NewSiloClient(conn)
+}
+
+func scan(t *testing.T, client silov1.SiloClient, rows ...map[string]any) *silov1.ScanSummary {
+	t.Helper()
+
+	stream, err := client.Scan(context.Background())
+
+	require.NoError(t, err)
+
+	for _, row := range rows {
+		message, err := structpb.NewStruct(row)
+
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&silov1.DataRow{Fields: message.GetFields()})) //nolint:exhaustruct
+	}
+
+	summary, err := stream.CloseAndRecv()
+
+	require.NoError(t, err)
+
+	return summary
+}
+
+func node(t *testing.T, id string, key any) *silov1.Node {
+	t.Helper()
+
+	value, err := structpb.NewValue(key)
+
+	require.NoError(t, err)
+
+	return &silov1.Node{Id: id, Key: value} //nolint:exhaustruct
+}
+
+func dump(t *testing.T, client silov1.SiloClient, request *silov1.DumpRequest) []*silov1.Entity {
+	t.Helper()
+
+	stream, err := client.Dump(context.Background(), request)
+
+	require.NoError(t, err)
+
+	entities := []*silov1.Entity{}
+
+	for {
+		message, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		if entity := message.GetEntity(); entity != nil {
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities
+}
+
+func TestScanLookup(t *testing.T) {
+	t.Parallel()
+
+	client := connect(t, silo.NewBackendInMemory())
+
+	summary := scan(t, client,
+		map[string]any{"ID": "1", "EMAIL": "a@domain.com"},
+		map[string]any{"ID": "2", "EMAIL": "b@domain.com"},
+		map[string]any{"ID": "2", "PHONE": "0601"},
+	)
+
+	assert.Equal(t, int64(3), summary.GetRows())
+	assert.Equal(t, int64(3), summary.GetLinks())
+
+	entity, err := client.Lookup(context.Background(),
+		&silov1.LookupRequest{Node: node(t, "PHONE", "0601")}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+	assert.Equal(t, "consistent", entity.GetStatus())
+
+	nodes := map[string]any{}
+	for _, item := range entity.GetNodes() {
+		nodes[item.GetId()] = item.GetKey().AsInterface()
+	}
+
+	assert.Equal(t, map[string]any{"PHONE": "0601", "ID": "2", "EMAIL": "b@domain.com"}, nodes)
+
+	_, err = client.Lookup(context.Background(),
+		&silov1.LookupRequest{Node: node(t, "PHONE", "0000")}) //nolint:exhaustruct
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	client := connect(t, silo.NewBackendInMemory())
+
+	scan(t, client,
+		map[string]any{"ID": "1", "EMAIL": "a@domain.com"},
+		map[string]any{"ID": "2", "EMAIL": "b@domain.com"},
+		map[string]any{"ID": "3"},
+	)
+
+	stream, err := client.Dump(context.Background(), &silov1.DumpRequest{Progress: 2}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+
+	entities, nodes, progress := 0, 0, []*silov1.Progress{}
+
+	for {
+		message, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		if entity := message.GetEntity(); entity != nil {
+			entities++
+			nodes += len(entity.GetNodes())
+		} else {
+			progress = append(progress, message.GetProgress())
+		}
+	}
+
+	assert.Equal(t, 3, entities)
+	assert.Equal(t, 5, nodes)
+	require.Len(t, progress, 2)
+	assert.Equal(t, int64(2), progress[0].GetEntities())
+	assert.Equal(t, map[string]int64{"consistent": 2}, progress[0].GetStatuses())
+	assert.Equal(t, int64(3), progress[1].GetEntities())
+	assert.Equal(t, map[string]int64{"consistent": 3}, progress[1].GetStatuses())
+}
+
+func TestDumpStatuses(t *testing.T) {
+	t.Parallel()
+
+	client := connect(t, silo.NewBackendInMemory())
+
+	scan(t, client,
+		map[string]any{"ID": "1", "EMAIL": "a@domain.com"},
+		map[string]any{"ID": "1", "EMAIL": "b@domain.com"},
+		map[string]any{"ID": "2", "EMAIL": "c@domain.com"},
+	)
+
+	entities := dump(t, client, &silov1.DumpRequest{Statuses: []string{"inconsistent"}}) //nolint:exhaustruct
+
+	require.Len(t, entities, 1)
+	assert.Equal(t, "inconsistent", entities[0].GetStatus())
+	assert.Equal(t, map[string]int64{"ID": 1, "EMAIL": 2}, entities[0].GetCounts())
+}
+
+func TestLookupDuringScan(t *testing.T) {
+	t.Parallel()
+
+	client := connect(t, silo.NewBackendInMemory())
+
+	scan(t, client, map[string]any{"ID": "1", "EMAIL": "a@domain.com"})
+
+	// an idle client keeps its scan stream open
+	stream, err := client.Scan(context.Background())
+
+	require.NoError(t, err)
+
+	message, err := structpb.NewStruct(map[string]any{"ID": "2"})
+
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&silov1.DataRow{Fields: message.GetFields()})) //nolint:exhaustruct
+
+	// the row sent by the idle client is visible before the end of its scan
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Lookup(ctx, &silov1.LookupRequest{Node: node(t, "ID", "2")}) //nolint:exhaustruct
+
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "a lookup must not wait for the end of a scan")
+
+	entity, err := client.Lookup(context.Background(),
+		&silov1.LookupRequest{Node: node(t, "ID", "1")}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+	assert.Len(t, entity.GetNodes(), 2)
+
+	_, err = stream.CloseAndRecv()
+
+	require.NoError(t, err)
+}
+
+func TestLookupOptions(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+	row := silo.DataRow{"ID": "1", "EMAIL": "a@domain.com"}
+
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory([]silo.DataRow{row})))
+
+	client := connect(t, backend, silo.WithMandatoryKeys([]string{"PHONE"}))
+
+	entity, err := client.Lookup(context.Background(),
+		&silov1.LookupRequest{Node: node(t, "ID", "1")}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+	assert.Equal(t, "empty", entity.GetStatus(), "the mandatory key of the server is missing")
+
+	entities := dump(t, client, &silov1.DumpRequest{Statuses: []string{"empty"}}) //nolint:exhaustruct
+
+	require.Len(t, entities, 1, "dumps and lookups use the same options")
+	assert.Equal(t, entity.GetCounts(), entities[0].GetCounts())
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrNodeNotFound     = errors.New("node not found")
+)
+
+// Server exposes a silo database through an HTTP/JSON API, scans, lookups and dumps run concurrently.
+// Each request builds its own driver on the shared backend, a dump streams from a snapshot.
+type Server struct {
+	backend silo.Backend
+	options []silo.Option
+}
+
+// NewServer returns a server sharing backend between requests, options are applied to scans, lookups and dumps.
+func NewServer(backend silo.Backend, options ...silo.Option) *Server {
+	return &Server{backend: backend, options: options}
+}
+
+// Handler returns the routes of the API :
+//
+//	POST /scan    ingest rows sent as JSON lines
+//	POST /lookup  return the entity containing the node sent as {"id":"key","key":value}
+//	GET  /dump    stream all entities as JSON lines, filtered by status parameters
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/scan", only(http.MethodPost, s.scan))
+	mux.HandleFunc("/lookup", only(http.MethodPost, s.lookup))
+	mux.HandleFunc("/dump", only(http.MethodGet, s.dump))
+
+	return mux
+}
+
+type scanResponse struct {
+	Rows  int `json:"rows"`
+	Links int `json:"links"`
+}
+
+func (r *scanResponse) IngestedRow(_ silo.DataRow) {
+	r.Rows++
+}
+
+func (r *scanResponse) IngestedLink(_ silo.DataLink) {
+	r.Links++
+}
+
+func (s *Server) scan(w http.ResponseWriter, r *http.Request) {
+	options := append([]silo.Option{silo.WithSource(source(r))}, s.options...)
+	response := &scanResponse{Rows: 0, Links: 0}
+
+	driver := silo.NewDriver(s.backend, nil, options...)
+
+	if err := driver.ScanContext(r.Context(), infra.NewDataRowReaderJSONLineFrom(r.Body), response); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type lookupRequest struct {
+	ID  string `json:"id"`
+	Key any    `json:"key"`
+}
+
+type lookupNode struct {
+	ID  string `json:"id"`
+	Key any    `json:"key"`
+}
+
+type lookupResponse struct {
+	UUID       string         `json:"uuid"`
+	Status     silo.Status    `json:"status"`
+	Counts     map[string]int `json:"counts"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Nodes      []lookupNode   `json:"nodes"`
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) {
+	var request lookupRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	driver := silo.NewDriver(s.backend, nil, s.options...)
+
+	entity, nodes, found, err := driver.Lookup(silo.DataNode{Key: request.ID, Data: request.Key})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w : %v=%v", ErrNodeNotFound, request.ID, request.Key))
+
+		return
+	}
+
+	response := lookupResponse{
+		UUID:       entity.UUID,
+		Status:     entity.Status,
+		Counts:     entity.Counts,
+		Attributes: entity.Attributes,
+		Nodes:      make([]lookupNode, 0, len(nodes)),
+	}
+
+	for _, node := range nodes {
+		response.Nodes = append(response.Nodes, lookupNode{ID: node.Key, Key: node.Data})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) dump(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	statuses := make([]silo.Status, 0, len(query["status"]))
+	for _, status := range query["status"] {
+		statuses = append(statuses, silo.Status(status))
+	}
+
+	options := append([]silo.Option{silo.WithStatuses(statuses)}, s.options...)
+
+	driver, err := newDriver(s.backend, newFlushWriter(w), query.Has("with-status"), options...)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	// the status code is already sent, an error can only interrupt the stream
+	if err := driver.DumpContext(r.Context()); err != nil {
+		log.Error().Err(err).Msg("dump interrupted")
+	}
+}
+
+// newDriver recovers the configuration errors reported by silo.NewDriver.
+func newDriver(
+	backend silo.Backend,
+	output io.Writer,
+	withStatus bool,
+	options ...silo.Option,
+) (driver *silo.Driver, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if errs, ok := r.([]error); ok {
+				err = errors.Join(errs...)
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	return silo.NewDriver(backend, infra.NewDumpJSONLineTo(output, withStatus), options...), nil
+}
+
+// flushWriter sends each dumped line to the client as soon as it is written.
+type flushWriter struct {
+	writer     io.Writer
+	controller *http.ResponseController
+}
+
+func newFlushWriter(w http.ResponseWriter) flushWriter {
+	return flushWriter{writer: w, controller: http.NewResponseController(w)}
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.writer.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	if err := f.controller.Flush(); err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	return n, nil
+}
+
+func source(r *http.Request) string {
+	if source := r.URL.Query().Get("source"); source != "" {
+		return source
+	}
+
+	return "http"
+}
+
+func only(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%w : %v", ErrMethodNotAllowed, r.Method))
+
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("unable to write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
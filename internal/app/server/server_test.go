@@ -0,0 +1,302 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package server_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cgi-fr/silo/internal/app/server"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rows = `{"ID":"1","EMAIL":"a@domain.com"}
+{"ID":"2","EMAIL":"b@domain.com"}
+{"ID":"2","PHONE":"0601"}
+`
+
+func post(t *testing.T, url string, body string) (*http.Response, map[string]any) {
+	t.Helper()
+
+	response, err := http.Post(url, "application/json", strings.NewReader(body)) //nolint:noctx
+
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	result := map[string]any{}
+
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+
+	return response, result
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	httpServer := httptest.NewServer(server.NewServer(silo.NewBackendInMemory()).Handler())
+	defer httpServer.Close()
+
+	response, result := post(t, httpServer.URL+"/scan", rows)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, map[string]any{"rows": 3.0, "links": 3.0}, result)
+
+	response, result = post(t, httpServer.URL+"/lookup", `{"id":"PHONE","key":"0601"}`)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "consistent", result["status"])
+	assert.ElementsMatch(t, []any{
+		map[string]any{"id": "PHONE", "key": "0601"},
+		map[string]any{"id": "ID", "key": "2"},
+		map[string]any{"id": "EMAIL", "key": "b@domain.com"},
+	}, result["nodes"])
+
+	response, _ = post(t, httpServer.URL+"/lookup", `{"id":"PHONE","key":"0000"}`)
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, err := http.Get(httpServer.URL + "/dump") //nolint:noctx
+
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(response.Body); scanner.Scan(); lines++ {
+		assert.Contains(t, scanner.Text(), `"uuid"`)
+	}
+
+	assert.Equal(t, 5, lines)
+}
+
+func TestServerConcurrentRequests(t *testing.T) {
+	t.Parallel()
+
+	httpServer := httptest.NewServer(server.NewServer(silo.NewBackendInMemory()).Handler())
+	defer httpServer.Close()
+
+	var wait sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wait.Add(2) //nolint:gomnd
+
+		go func() {
+			defer wait.Done()
+
+			response, err := http.Post(httpServer.URL+"/scan", "application/json", strings.NewReader(rows)) //nolint:noctx
+			if assert.NoError(t, err) {
+				response.Body.Close()
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+			}
+		}()
+
+		go func() {
+			defer wait.Done()
+
+			request := strings.NewReader(`{"id":"ID","key":"1"}`)
+
+			response, err := http.Post(httpServer.URL+"/lookup", "application/json", request) //nolint:noctx
+			if assert.NoError(t, err) {
+				response.Body.Close()
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	response, result := post(t, httpServer.URL+"/lookup", `{"id":"ID","key":"1"}`)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Len(t, result["nodes"], 2)
+}
+
+// blockedBackend returns snapshots that block on their first read until released.
+type blockedBackend struct {
+	*silo.BackendInMemory
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (b blockedBackend) Snapshot() silo.Snapshot { //nolint:ireturn
+	return blockedSnapshot{Snapshot: b.BackendInMemory.Snapshot(), reading: b.reading, release: b.release}
+}
+
+type blockedSnapshot struct {
+	silo.Snapshot
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (s blockedSnapshot) Next() (silo.DataNode, bool, error) {
+	select {
+	case s.reading <- struct{}{}:
+		<-s.release
+	default:
+	}
+
+	return s.Snapshot.Next() //nolint:wrapcheck
+}
+
+func TestServerDumpDoesNotBlockScan(t *testing.T) {
+	t.Parallel()
+
+	backend := blockedBackend{
+		BackendInMemory: silo.NewBackendInMemory(),
+		reading:         make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+
+	httpServer := httptest.NewServer(server.NewServer(backend).Handler())
+	defer httpServer.Close()
+
+	post(t, httpServer.URL+"/scan", rows)
+
+	dumped := make(chan int)
+
+	go func() {
+		response, err := http.Get(httpServer.URL + "/dump") //nolint:noctx
+		if !assert.NoError(t, err) {
+			close(dumped)
+
+			return
+		}
+
+		defer response.Body.Close()
+
+		lines := 0
+		for scanner := bufio.NewScanner(response.Body); scanner.Scan(); {
+			lines++
+		}
+
+		dumped <- lines
+	}()
+
+	<-backend.reading
+
+	// the dump is stuck after taking its snapshot, a scan must not wait for it
+	response, result := post(t, httpServer.URL+"/scan", `{"ID":"3","EMAIL":"c@domain.com"}`)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, map[string]any{"rows": 1.0, "links": 1.0}, result)
+
+	close(backend.release)
+
+	assert.Equal(t, 5, <-dumped, "the dump reads its snapshot, without the rows scanned after it")
+}
+
+func TestServerLookupOptions(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+	row := silo.DataRow{"ID": "1", "EMAIL": "a@domain.com"}
+
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory([]silo.DataRow{row})))
+
+	httpServer := httptest.NewServer(server.NewServer(backend, silo.WithMandatoryKeys([]string{"ID"})).Handler())
+	defer httpServer.Close()
+
+	response, result := post(t, httpServer.URL+"/lookup", `{"id":"ID","key":"1"}`)
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "complete", result["status"], "the mandatory key of the server is present")
+
+	dump, err := http.Get(httpServer.URL + "/dump?status=complete") //nolint:noctx
+
+	require.NoError(t, err)
+
+	defer dump.Body.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(dump.Body); scanner.Scan(); {
+		lines++
+	}
+
+	assert.Equal(t, 1, lines, "dumps and lookups use the same options")
+}
+
+func TestServerLookupDuringScan(t *testing.T) {
+	t.Parallel()
+
+	httpServer := httptest.NewServer(server.NewServer(silo.NewBackendInMemory()).Handler())
+	defer httpServer.Close()
+
+	post(t, httpServer.URL+"/scan", rows)
+
+	// a slow client trickles the body of its scan
+	body, writer := io.Pipe()
+	scanned := make(chan int)
+
+	go func() {
+		response, err := http.Post(httpServer.URL+"/scan", "application/json", body) //nolint:noctx
+		if !assert.NoError(t, err) {
+			close(scanned)
+
+			return
+		}
+
+		response.Body.Close()
+		scanned <- response.StatusCode
+	}()
+
+	_, err := writer.Write([]byte(`{"ID":"3","EMAIL":"c@domain.com"}` + "\n"))
+
+	require.NoError(t, err)
+
+	client := &http.Client{Timeout: 100 * time.Millisecond} //nolint:exhaustruct
+
+	// the row already sent is visible before the end of the scan
+	require.Eventually(t, func() bool {
+		response, err := client.Post(httpServer.URL+"/lookup", "application/json", //nolint:noctx
+			strings.NewReader(`{"id":"ID","key":"3"}`))
+		if err != nil {
+			return false
+		}
+
+		response.Body.Close()
+
+		return response.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, "a lookup must not wait for the end of a scan")
+
+	require.NoError(t, writer.Close())
+	assert.Equal(t, http.StatusOK, <-scanned)
+}
+
+func TestServerMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	httpServer := httptest.NewServer(server.NewServer(silo.NewBackendInMemory()).Handler())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/scan") //nolint:noctx
+
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
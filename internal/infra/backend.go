@@ -20,17 +20,28 @@
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/cgi-fr/silo/pkg/silo"
 	"github.com/cockroachdb/pebble"
 	"github.com/rs/zerolog/log"
 )
 
+// metadataKey is sorted before all nodes, a gob encoded node never starts with a zero byte.
+var metadataKey = []byte("\x00metadata") //nolint:gochecknoglobals
+
+// nodesIterOptions returns options to iterate over nodes only, skipping reserved keys.
+func nodesIterOptions() *pebble.IterOptions {
+	return &pebble.IterOptions{LowerBound: []byte{0x01}} //nolint:exhaustruct
+}
+
 func decode(value []byte) ([]silo.DataNode, error) {
 	var set map[silo.DataNode]any
 
@@ -67,120 +78,277 @@ func encode(items []silo.DataNode) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
-type Snapshot struct {
-	db *pebble.Batch
+// Snapshot is the snapshot of the default dump strategy, a single ordered iteration over a pebble snapshot.
+type Snapshot = SnapshotInterateOnce
+
+// Backend is safe for concurrent use, updates of a set of links are serialized.
+type Backend struct {
+	db       *pebble.DB
+	readOnly bool
+	mutex    *sync.Mutex
+	version  *int64 // version of the last change, guarded by mutex
 }
 
-func (s Snapshot) Next() (silo.DataNode, bool, error) {
-	iter, err := s.db.NewIter(&pebble.IterOptions{}) //nolint:exhaustruct
+func (b Backend) Get(node silo.DataNode) ([]silo.DataNode, error) {
+	key, err := node.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	item, closer, err := b.db.Get(key)
 	if errors.Is(err, pebble.ErrNotFound) {
-		return silo.DataNode{Key: "", Data: ""}, false, nil
+		return []silo.DataNode{}, nil
 	} else if err != nil {
-		return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+		return nil, fmt.Errorf("%w", err)
 	}
 
-	defer iter.Close()
+	defer closer.Close()
+
+	return decode(item)
+}
+
+func (b Backend) Store(key silo.DataNode, value silo.DataNode) error {
+	return b.StoreAll(key, value)
+}
+
+// StoreAll adds all values to the set of key with a single write.
+func (b Backend) StoreAll(key silo.DataNode, values ...silo.DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	nodes, err := b.Get(key)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	nodes = append(nodes, values...)
 
-	if !iter.First() {
-		return silo.DataNode{Key: "", Data: ""}, false, nil
+	rawNodes, err := encode(nodes)
+	if err != nil {
+		return fmt.Errorf("%w", err)
 	}
 
-	key, err := silo.DecodeDataNode(iter.Key())
+	rawKey, err := key.Binary()
 	if err != nil {
-		return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+		return fmt.Errorf("%w", err)
 	}
 
-	return key, true, nil
+	return b.write(rawKey, rawNodes)
 }
 
-func (s Snapshot) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
-	key, err := node.Binary()
+func (b Backend) Remove(key silo.DataNode, value silo.DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	nodes, err := b.Get(key)
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return fmt.Errorf("%w", err)
 	}
 
-	item, closer, err := s.db.Get(key)
-	if errors.Is(err, pebble.ErrNotFound) {
-		return []silo.DataNode{}, nil
-	} else if err != nil {
-		return nil, fmt.Errorf("%w", err)
+	remaining := make([]silo.DataNode, 0, len(nodes))
+
+	for _, node := range nodes {
+		if node != value {
+			remaining = append(remaining, node)
+		}
 	}
-	defer closer.Close()
 
-	set, err := decode(item)
+	if len(remaining) == len(nodes) {
+		return nil
+	}
+
+	if len(remaining) == 0 {
+		return b.delete(key)
+	}
+
+	rawNodes, err := encode(remaining)
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return fmt.Errorf("%w", err)
 	}
 
-	if err := s.db.Delete(key, pebble.NoSync); err != nil {
-		return nil, fmt.Errorf("%w", err)
+	rawKey, err := key.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return b.write(rawKey, rawNodes)
+}
+
+func (b Backend) Delete(key silo.DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.delete(key)
+}
+
+func (b Backend) delete(key silo.DataNode) error {
+	rawKey, err := key.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
 	}
 
-	return set, nil
+	return b.write(rawKey, nil)
 }
 
-func (s Snapshot) Close() error {
-	if err := s.db.Close(); err != nil {
+// write sets the set of a node and marks the node as touched in a single batch. If rawNodes is nil, the node is
+// deleted with its change and its entity, so no key holds its value anymore. It must be called with the mutex held.
+func (b Backend) write(rawKey []byte, rawNodes []byte) error {
+	batch := b.db.NewBatch()
+	defer batch.Close()
+
+	if rawNodes == nil {
+		if err := b.erase(batch, rawKey); err != nil {
+			return err
+		}
+	} else {
+		if err := batch.Set(rawKey, rawNodes, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := b.touch(batch, rawKey); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	return nil
 }
 
-type Backend struct {
-	db *pebble.DB
+// ForEach calls fn with each node and its set, without modifying the database.
+func (b Backend) ForEach(fn func(key silo.DataNode, values []silo.DataNode) error) error {
+	iter, err := b.db.NewIter(nodesIterOptions())
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		key, err := decodeKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		values, err := decode(iter.Value())
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := fn(key, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (b Backend) Get(node silo.DataNode) ([]silo.DataNode, error) {
-	key, err := node.Binary()
+// Merge adds all links stored in source to the backend, keys are renamed with given aliases.
+// It returns the number of nodes read from source.
+func (b Backend) Merge(source Storage, aliases map[string]string) (int, error) {
+	return Merge(b, source, aliases)
+}
+
+// Merge adds all links and scan records stored in source to target, keys are renamed with given aliases.
+// It returns the number of nodes read from source.
+func Merge(target Storage, source Storage, aliases map[string]string) (int, error) {
+	count := 0
+
+	err := source.ForEach(func(key silo.DataNode, values []silo.DataNode) error {
+		renamed := make([]silo.DataNode, 0, len(values))
+
+		for _, value := range values {
+			renamed = append(renamed, value.Rename(aliases))
+		}
+
+		count++
+
+		return storeAll(target, key.Rename(aliases), renamed...)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return count, fmt.Errorf("%w", err)
 	}
 
-	item, closer, err := b.db.Get(key)
+	metadata, err := source.Metadata()
+	if err != nil {
+		return count, fmt.Errorf("%w", err)
+	}
+
+	return count, appendMetadata(target, metadata)
+}
+
+// Metadata returns the metadata of the silo, with no scan if the silo has no metadata.
+func (b Backend) Metadata() (silo.Metadata, error) {
+	metadata := silo.Metadata{Scans: []silo.ScanRecord{}, Checkpoints: map[string]silo.Checkpoint{}, Compacted: false}
+
+	item, closer, err := b.db.Get(metadataKey)
 	if errors.Is(err, pebble.ErrNotFound) {
-		return []silo.DataNode{}, nil
+		return metadata, nil
 	} else if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return metadata, fmt.Errorf("%w", err)
 	}
 
 	defer closer.Close()
 
-	return decode(item)
-}
-
-func (b Backend) Store(key silo.DataNode, value silo.DataNode) error {
-	nodes, err := b.Get(key)
-	if err != nil {
-		return fmt.Errorf("%w", err)
+	if err := json.Unmarshal(item, &metadata); err != nil {
+		return metadata, fmt.Errorf("%w", err)
 	}
 
-	nodes = append(nodes, value)
+	return metadata, nil
+}
 
-	rawNodes, err := encode(nodes)
+func (b Backend) SetMetadata(metadata silo.Metadata) error {
+	raw, err := json.Marshal(metadata)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	rawKey, err := key.Binary()
-	if err != nil {
+	if err := b.db.Set(metadataKey, raw, pebble.Sync); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	if err := b.db.Set(rawKey, rawNodes, pebble.NoSync); err != nil {
+	return nil
+}
+
+// Sync writes the log of the database to disk, so that the updates committed without sync survive a crash.
+func (b Backend) Sync() error {
+	if err := b.db.LogData(nil, pebble.Sync); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
 	return nil
 }
 
+// AppendMetadata adds the scan records and the checkpoints of other to the metadata of the silo.
+func (b Backend) AppendMetadata(other silo.Metadata) error {
+	if len(other.Scans) == 0 && len(other.Checkpoints) == 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	metadata, err := b.Metadata()
+	if err != nil {
+		return err
+	}
+
+	metadata.Append(other)
+
+	return b.SetMetadata(metadata)
+}
+
 func (b Backend) Snapshot() silo.Snapshot { //nolint:ireturn
-	return Snapshot{b.db.NewIndexedBatch()}
+	return NewSnapshotInterateOnce(b.db)
 }
 
 func (b Backend) Close() error {
-	if err := b.db.Flush(); err != nil {
-		return fmt.Errorf("%w", err)
+	if !b.readOnly {
+		if err := b.db.Flush(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
 	}
 
 	if err := b.db.Close(); err != nil {
@@ -190,17 +358,57 @@ func (b Backend) Close() error {
 	return nil
 }
 
+// initialVersion returns the first version of the changes recorded by a backend opened now. Versions are not persisted,
+// they start from the current time so that they still increase across successive openings of the database.
+func initialVersion() int64 {
+	return time.Now().UnixNano()
+}
+
+func newBackend(database *pebble.DB, readOnly bool) Backend {
+	version := initialVersion()
+
+	return Backend{db: database, readOnly: readOnly, mutex: &sync.Mutex{}, version: &version}
+}
+
 func NewBackend(path string) (Backend, error) {
 	if err := checkDirectory(path); err != nil {
 		return Backend{}, fmt.Errorf("unable to open database %v : %w", path, err)
 	}
 
-	database, err := pebble.Open(path, &pebble.Options{Logger: BackendLogger{}}) //nolint:exhaustruct
+	options := &pebble.Options{Logger: BackendLogger{}, Cleaner: eraseCleaner{}} //nolint:exhaustruct
+
+	database, err := pebble.Open(path, options)
+	if err != nil {
+		return Backend{}, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	return newBackend(database, false), nil
+}
+
+// eraseCleaner deletes obsolete files like the default cleaner, but it also
+// prevents pebble from recycling obsolete logs, which would keep the values of
+// deleted nodes on disk until they are overwritten.
+type eraseCleaner struct {
+	pebble.DeleteCleaner
+}
+
+// NeedsFileContents disables the recycling of obsolete logs.
+func (eraseCleaner) NeedsFileContents() {}
+
+// NewBackendReadOnly opens an existing database without allowing any modification.
+func NewBackendReadOnly(path string) (Backend, error) {
+	if err := checkDirectory(path); err != nil {
+		return Backend{}, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	options := &pebble.Options{Logger: BackendLogger{}, ReadOnly: true} //nolint:exhaustruct
+
+	database, err := pebble.Open(path, options)
 	if err != nil {
 		return Backend{}, fmt.Errorf("unable to open database %v : %w", path, err)
 	}
 
-	return Backend{db: database}, nil
+	return newBackend(database, true), nil
 }
 
 type BackendLogger struct{}
@@ -0,0 +1,638 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"go.etcd.io/bbolt"
+)
+
+// buckets of a bolt silo : the sets of the nodes, and the metadata and change tracking kept by the pebble backend
+// under reserved keys.
+//
+//nolint:gochecknoglobals
+var (
+	boltNodes    = []byte("nodes")
+	boltMetadata = []byte("metadata")
+	boltTouched  = []byte("touched")
+	boltEntities = []byte("entities")
+	boltRemoved  = []byte("removed")
+	boltBuckets  = [][]byte{boltNodes, boltMetadata, boltTouched, boltEntities, boltRemoved}
+)
+
+const (
+	// boltBatchSize is the number of updates grouped in a write transaction before it is committed.
+	boltBatchSize = 1000
+
+	// boltLockTimeout bounds the wait for the lock of a file opened by another process.
+	boltLockTimeout = time.Second
+
+	boltFileMode = 0o600
+)
+
+// boltMmapSize returns the size of the initial memory mapping of the file. A write transaction that grows the file
+// beyond the mapping waits for the read transactions of the dumps in progress, so the mapping is large enough for most
+// silos. It is virtual memory, only the pages read are loaded, but Windows extends the file to the size of its mapping
+// so the default mapping is kept there.
+func boltMmapSize() int {
+	if runtime.GOOS == "windows" {
+		return 0
+	}
+
+	return 1 << 30 //nolint:gomnd
+}
+
+// BackendBolt stores the silo in a single bbolt file.
+type BackendBolt struct {
+	db      *bbolt.DB
+	path    string
+	mutex   sync.Mutex
+	tx      *bbolt.Tx // write transaction of the pending updates, guarded by mutex
+	pending int       // number of updates in tx, guarded by mutex
+	version int64     // version of the last change, guarded by mutex
+}
+
+func NewBackendBolt(path string) (*BackendBolt, error) {
+	return openBoltFile(path, false)
+}
+
+// NewBackendBoltReadOnly opens an existing bolt silo without allowing any modification.
+func NewBackendBoltReadOnly(path string) (*BackendBolt, error) {
+	return openBoltFile(path, true)
+}
+
+func openBolt(location string, _ url.Values, readOnly bool) (Storage, error) { //nolint:ireturn
+	return openBoltFile(location, readOnly)
+}
+
+func boltOptions(readOnly bool) *bbolt.Options {
+	return &bbolt.Options{ //nolint:exhaustruct
+		ReadOnly:        readOnly,
+		Timeout:         boltLockTimeout,
+		InitialMmapSize: boltMmapSize(),
+	}
+}
+
+func openBoltFile(path string, readOnly bool) (*BackendBolt, error) {
+	database, err := bbolt.Open(path, boltFileMode, boltOptions(readOnly))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	check := database.Update
+	if readOnly {
+		check = database.View
+	}
+
+	err = check(func(tx *bbolt.Tx) error {
+		for _, name := range boltBuckets {
+			if tx.Writable() {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return fmt.Errorf("%w", err)
+				}
+			} else if tx.Bucket(name) == nil {
+				return fmt.Errorf("%w '%s'", ErrPathIsNotValid, path)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		database.Close()
+
+		return nil, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	return &BackendBolt{db: database, path: path, mutex: sync.Mutex{}, tx: nil, pending: 0, version: initialVersion()},
+		nil
+}
+
+// write runs fn in the write transaction, and commits the transaction once it groups boltBatchSize updates. The values
+// written by fn are encoded before the first write, so that a failed update leaves the transaction unchanged. The
+// mutex must be held.
+func (b *BackendBolt) write(fn func(tx *bbolt.Tx) error) error {
+	if b.tx == nil {
+		tx, err := b.db.Begin(true)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		b.tx = tx
+	}
+
+	if err := fn(b.tx); err != nil {
+		return err
+	}
+
+	b.pending++
+
+	if b.pending >= boltBatchSize {
+		return b.commit()
+	}
+
+	return nil
+}
+
+// commit commits the write transaction, if any. The mutex must be held.
+func (b *BackendBolt) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+
+	tx := b.tx
+	b.tx, b.pending = nil, 0
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// flush commits the pending updates, so that they are read out of the write transaction.
+func (b *BackendBolt) flush() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.commit()
+}
+
+// read runs fn in the write transaction if it is pending, so that it reads the pending updates, or in a read
+// transaction. The mutex must be held.
+func (b *BackendBolt) read(fn func(tx *bbolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+
+	return b.db.View(fn) //nolint:wrapcheck
+}
+
+// boltSet returns the set of the node stored in bucket, an empty set if the node is not stored.
+func boltSet(bucket *bbolt.Bucket, rawKey []byte) ([]silo.DataNode, error) {
+	item := bucket.Get(rawKey)
+	if item == nil {
+		return []silo.DataNode{}, nil
+	}
+
+	return decode(item)
+}
+
+// touch marks the node as touched, like Backend.touch. The mutex must be held.
+func (b *BackendBolt) touch(tx *bbolt.Tx, rawKey []byte) error {
+	b.version++
+
+	if err := tx.Bucket(boltTouched).Put(rawKey, binary.BigEndian.AppendUint64(nil, uint64(b.version))); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// erase deletes the set, the change and the entity of the node, and records the id of its entity as removed, like
+// Backend.erase. The mutex must be held.
+func (b *BackendBolt) erase(tx *bbolt.Tx, rawKey []byte) error {
+	if uuid := tx.Bucket(boltEntities).Get(rawKey); uuid != nil {
+		b.version++
+
+		version := binary.BigEndian.AppendUint64(nil, uint64(b.version))
+
+		if err := tx.Bucket(boltRemoved).Put(append([]byte{}, uuid...), version); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	for _, name := range [][]byte{boltNodes, boltTouched, boltEntities} {
+		if err := tx.Bucket(name).Delete(rawKey); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// set replaces the set of the node and touches it. The mutex must be held.
+func (b *BackendBolt) set(tx *bbolt.Tx, rawKey []byte, rawNodes []byte) error {
+	if err := tx.Bucket(boltNodes).Put(rawKey, rawNodes); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return b.touch(tx, rawKey)
+}
+
+func (b *BackendBolt) Store(key silo.DataNode, value silo.DataNode) error {
+	return b.StoreAll(key, value)
+}
+
+// StoreAll adds all values to the set of key with a single write.
+func (b *BackendBolt) StoreAll(key silo.DataNode, values ...silo.DataNode) error {
+	rawKey, err := key.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *bbolt.Tx) error {
+		nodes, err := boltSet(tx.Bucket(boltNodes), rawKey)
+		if err != nil {
+			return err
+		}
+
+		rawNodes, err := encode(append(nodes, values...))
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return b.set(tx, rawKey, rawNodes)
+	})
+}
+
+func (b *BackendBolt) Get(key silo.DataNode) ([]silo.DataNode, error) {
+	rawKey, err := key.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var nodes []silo.DataNode
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = b.read(func(tx *bbolt.Tx) error {
+		nodes, err = boltSet(tx.Bucket(boltNodes), rawKey)
+
+		return err
+	})
+
+	return nodes, err
+}
+
+func (b *BackendBolt) Remove(key silo.DataNode, value silo.DataNode) error {
+	rawKey, err := key.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *bbolt.Tx) error {
+		nodes, err := boltSet(tx.Bucket(boltNodes), rawKey)
+		if err != nil {
+			return err
+		}
+
+		remaining := make([]silo.DataNode, 0, len(nodes))
+
+		for _, node := range nodes {
+			if node != value {
+				remaining = append(remaining, node)
+			}
+		}
+
+		if len(remaining) == len(nodes) {
+			return nil
+		}
+
+		if len(remaining) == 0 {
+			return b.erase(tx, rawKey)
+		}
+
+		rawNodes, err := encode(remaining)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return b.set(tx, rawKey, rawNodes)
+	})
+}
+
+func (b *BackendBolt) Delete(key silo.DataNode) error {
+	rawKey, err := key.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *bbolt.Tx) error {
+		return b.erase(tx, rawKey)
+	})
+}
+
+// ForEach calls fn with each node and its set.
+func (b *BackendBolt) ForEach(fn func(key silo.DataNode, values []silo.DataNode) error) error {
+	// fn can update the silo, so the sets are read out of the write transaction
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	return b.db.View(func(tx *bbolt.Tx) error { //nolint:wrapcheck
+		return tx.Bucket(boltNodes).ForEach(func(rawKey, rawNodes []byte) error {
+			key, err := decodeKey(rawKey)
+			if err != nil {
+				return err
+			}
+
+			values, err := decode(rawNodes)
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			return fn(key, values)
+		})
+	})
+}
+
+// Metadata returns the metadata of the silo, with no scan if the silo has no metadata.
+func (b *BackendBolt) Metadata() (silo.Metadata, error) {
+	metadata := silo.Metadata{Scans: []silo.ScanRecord{}, Checkpoints: map[string]silo.Checkpoint{}, Compacted: false}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.read(func(tx *bbolt.Tx) error {
+		item := tx.Bucket(boltMetadata).Get(boltMetadata)
+		if item == nil {
+			return nil
+		}
+
+		return json.Unmarshal(item, &metadata) //nolint:wrapcheck
+	})
+	if err != nil {
+		return metadata, fmt.Errorf("%w", err)
+	}
+
+	return metadata, nil
+}
+
+func (b *BackendBolt) SetMetadata(metadata silo.Metadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = b.write(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltMetadata).Put(boltMetadata, content) //nolint:wrapcheck
+	})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// a checkpoint is committed with the rows it counts
+	return b.commit()
+}
+
+// Changes returns the nodes touched since they were last untouched, then the removed entities.
+func (b *BackendBolt) Changes() ([]silo.Change, error) {
+	changes := []silo.Change{}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.read(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(boltTouched).ForEach(func(rawKey, version []byte) error {
+			node, err := decodeKey(rawKey)
+			if err != nil {
+				return err
+			}
+
+			changes = append(changes, silo.Change{
+				Node: node, Version: int64(binary.BigEndian.Uint64(version)), Removed: "",
+			})
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(boltRemoved).ForEach(func(uuid, version []byte) error {
+			changes = append(changes, silo.Change{
+				Node: silo.DataNode{Key: "", Data: nil}, Version: int64(binary.BigEndian.Uint64(version)),
+				Removed: string(uuid),
+			})
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return changes, nil
+}
+
+// Untouch forgets the changes, unless their node was touched again since.
+func (b *BackendBolt) Untouch(changes ...silo.Change) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.write(func(tx *bbolt.Tx) error {
+		for _, change := range changes {
+			bucket, key := tx.Bucket(boltRemoved), []byte(change.Removed)
+
+			if change.Removed == "" {
+				rawKey, err := change.Node.Binary()
+				if err != nil {
+					return fmt.Errorf("%w", err)
+				}
+
+				bucket, key = tx.Bucket(boltTouched), rawKey
+			}
+
+			item := bucket.Get(key)
+			if item == nil || int64(binary.BigEndian.Uint64(item)) != change.Version {
+				continue
+			}
+
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the end of a dump of changes is committed, like the pebble backend syncs it
+	return b.commit()
+}
+
+// EntityOf returns the id of the entity containing the node in the last dump of changes.
+func (b *BackendBolt) EntityOf(node silo.DataNode) (string, bool, error) {
+	rawKey, err := node.Binary()
+	if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	var uuid []byte
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = b.read(func(tx *bbolt.Tx) error {
+		if item := tx.Bucket(boltEntities).Get(rawKey); item != nil {
+			uuid = append([]byte{}, item...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	return string(uuid), uuid != nil, nil
+}
+
+// SetEntity records the id of the entity containing the nodes.
+func (b *BackendBolt) SetEntity(uuid string, nodes ...silo.DataNode) error {
+	rawKeys := make([][]byte, 0, len(nodes))
+
+	for _, node := range nodes {
+		rawKey, err := node.Binary()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		rawKeys = append(rawKeys, rawKey)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *bbolt.Tx) error {
+		for _, rawKey := range rawKeys {
+			if err := tx.Bucket(boltEntities).Put(rawKey, []byte(uuid)); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (b *BackendBolt) Snapshot() silo.Snapshot { //nolint:ireturn
+	// the snapshot reads the committed updates
+	err := b.flush()
+
+	var tx *bbolt.Tx
+	if err == nil {
+		tx, err = b.db.Begin(false)
+	}
+
+	return &SnapshotBolt{tx: tx, err: err, cursor: nil, pulled: make(map[string]bool, DefaultPulledMapCap)}
+}
+
+func (b *BackendBolt) Close() error {
+	if err := b.flush(); err != nil {
+		b.db.Close()
+
+		return err
+	}
+
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// SnapshotBolt traverses the sets within a single bolt read transaction, so it reads a point-in-time view.
+type SnapshotBolt struct {
+	tx     *bbolt.Tx
+	err    error // error of the transaction opening, returned by the first call
+	cursor *bbolt.Cursor
+	pulled map[string]bool
+}
+
+func (s *SnapshotBolt) Next() (silo.DataNode, bool, error) {
+	if s.err != nil {
+		return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", s.err)
+	}
+
+	var rawKey []byte
+
+	if s.cursor == nil {
+		s.cursor = s.tx.Bucket(boltNodes).Cursor()
+		rawKey, _ = s.cursor.First()
+	} else {
+		rawKey, _ = s.cursor.Next()
+	}
+
+	for ; rawKey != nil; rawKey, _ = s.cursor.Next() {
+		if s.pulled[string(rawKey)] {
+			continue
+		}
+
+		node, err := decodeKey(rawKey)
+		if err != nil {
+			return silo.DataNode{Key: "", Data: ""}, false, err
+		}
+
+		return node, true, nil
+	}
+
+	return silo.DataNode{Key: "", Data: ""}, false, nil
+}
+
+func (s *SnapshotBolt) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+	if s.err != nil {
+		return nil, fmt.Errorf("%w", s.err)
+	}
+
+	rawKey, err := node.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if s.pulled[string(rawKey)] {
+		return []silo.DataNode{}, nil
+	}
+
+	s.pulled[string(rawKey)] = true
+
+	return boltSet(s.tx.Bucket(boltNodes), rawKey)
+}
+
+func (s *SnapshotBolt) Close() error {
+	if s.err != nil {
+		return nil
+	}
+
+	if err := s.tx.Rollback(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cockroachdb/pebble"
+)
+
+// reserved keys of the change tracking, sorted before all nodes like the metadata key.
+var (
+	touchedPrefix = []byte("\x00touched/") //nolint:gochecknoglobals
+	entityPrefix  = []byte("\x00entity/")  //nolint:gochecknoglobals
+	removedPrefix = []byte("\x00removed/") //nolint:gochecknoglobals
+)
+
+func prefixed(prefix []byte, rawKey []byte) []byte {
+	return append(append(make([]byte, 0, len(prefix)+len(rawKey)), prefix...), rawKey...)
+}
+
+// upperBound returns the first key after all keys starting with prefix.
+func upperBound(prefix []byte) []byte {
+	bound := append([]byte{}, prefix...)
+	bound[len(bound)-1]++
+
+	return bound
+}
+
+// touch marks the node as touched in the batch, it must be called with the mutex held.
+func (b Backend) touch(batch *pebble.Batch, rawKey []byte) error {
+	*b.version++
+
+	version := binary.BigEndian.AppendUint64(nil, uint64(*b.version))
+
+	if err := batch.Set(prefixed(touchedPrefix, rawKey), version, nil); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// erase deletes the set, the change and the entity of the node in the batch : the dump of changes does not need the
+// change of a deleted node, its neighbours are touched when the node is removed from their sets. The id of its
+// entity is kept as removed, without the node, in case the entity has no node left. It must be called with the mutex
+// held.
+func (b Backend) erase(batch *pebble.Batch, rawKey []byte) error {
+	item, closer, err := b.db.Get(prefixed(entityPrefix, rawKey))
+	if err != nil && !errors.Is(err, pebble.ErrNotFound) {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err == nil {
+		uuid := append([]byte{}, item...)
+		closer.Close()
+
+		*b.version++
+
+		version := binary.BigEndian.AppendUint64(nil, uint64(*b.version))
+
+		if err := batch.Set(prefixed(removedPrefix, uuid), version, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	for _, key := range [][]byte{rawKey, prefixed(touchedPrefix, rawKey), prefixed(entityPrefix, rawKey)} {
+		if err := batch.Delete(key, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// Changes returns the nodes touched since they were last untouched, then the removed entities.
+func (b Backend) Changes() ([]silo.Change, error) {
+	changes := []silo.Change{}
+
+	for _, prefix := range [][]byte{touchedPrefix, removedPrefix} {
+		iter, err := b.db.NewIter(&pebble.IterOptions{ //nolint:exhaustruct
+			LowerBound: prefix,
+			UpperBound: upperBound(prefix),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		for iter.First(); iter.Valid(); iter.Next() {
+			change := silo.Change{
+				Node:    silo.DataNode{Key: "", Data: nil},
+				Version: int64(binary.BigEndian.Uint64(iter.Value())),
+				Removed: "",
+			}
+
+			if bytes.Equal(prefix, removedPrefix) {
+				change.Removed = string(iter.Key()[len(prefix):])
+			} else if change.Node, err = decodeKey(iter.Key()[len(prefix):]); err != nil {
+				iter.Close()
+
+				return nil, err
+			}
+
+			changes = append(changes, change)
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	return changes, nil
+}
+
+// changeKey returns the key recording the change.
+func changeKey(change silo.Change) ([]byte, error) {
+	if change.Removed != "" {
+		return prefixed(removedPrefix, []byte(change.Removed)), nil
+	}
+
+	rawKey, err := change.Node.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return prefixed(touchedPrefix, rawKey), nil
+}
+
+// Untouch forgets the changes, unless their node was touched again since.
+func (b Backend) Untouch(changes ...silo.Change) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	batch := b.db.NewBatch()
+	defer batch.Close()
+
+	for _, change := range changes {
+		key, err := changeKey(change)
+		if err != nil {
+			return err
+		}
+
+		item, closer, err := b.db.Get(key)
+		if errors.Is(err, pebble.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		version := int64(binary.BigEndian.Uint64(item))
+		closer.Close()
+
+		if version != change.Version {
+			continue
+		}
+
+		if err := batch.Delete(key, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// EntityOf returns the id of the entity containing the node in the last dump of changes.
+func (b Backend) EntityOf(node silo.DataNode) (string, bool, error) {
+	rawKey, err := node.Binary()
+	if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	item, closer, err := b.db.Get(prefixed(entityPrefix, rawKey))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	defer closer.Close()
+
+	return string(item), true, nil
+}
+
+// SetEntity records the id of the entity containing the nodes.
+func (b Backend) SetEntity(uuid string, nodes ...silo.DataNode) error {
+	batch := b.db.NewBatch()
+	defer batch.Close()
+
+	for _, node := range nodes {
+		rawKey, err := node.Binary()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := batch.Set(prefixed(entityPrefix, rawKey), []byte(uuid), nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -41,30 +41,34 @@ func (b BackendFull) Snapshot() silo.Snapshot { //nolint:ireturn
 	return NewSnapshotFull(b.db)
 }
 
+// SnapshotFull loads all links of a pebble snapshot in memory on first use.
 type SnapshotFull struct {
-	db     *pebble.DB
-	nodes  map[string][]byte
-	loaded bool
+	snapshot *pebble.Snapshot
+	nodes    map[string][]byte
+	loaded   bool
 }
 
 const DefaultFullMapCap = 1024
 
 func NewSnapshotFull(db *pebble.DB) silo.Snapshot { //nolint:ireturn
 	return &SnapshotFull{
-		db:     db,
-		nodes:  make(map[string][]byte, DefaultFullMapCap),
-		loaded: false,
+		snapshot: db.NewSnapshot(),
+		nodes:    make(map[string][]byte, DefaultFullMapCap),
+		loaded:   false,
 	}
 }
 
 func (s *SnapshotFull) Load() error {
-	iter, err := s.db.NewIter(&pebble.IterOptions{}) //nolint:exhaustruct
+	iter, err := s.snapshot.NewIter(nodesIterOptions())
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
+	defer iter.Close()
+
+	// the value is only valid until the next move of the iterator
 	for iter.First(); iter.Valid(); iter.Next() {
-		s.nodes[string(iter.Key())] = iter.Value()
+		s.nodes[string(iter.Key())] = append([]byte{}, iter.Value()...)
 	}
 
 	s.loaded = true
@@ -92,6 +96,12 @@ func (s *SnapshotFull) Next() (silo.DataNode, bool, error) {
 }
 
 func (s *SnapshotFull) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+	if !s.loaded {
+		if err := s.Load(); err != nil {
+			return nil, err
+		}
+	}
+
 	key, err := node.Binary()
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
@@ -113,5 +123,9 @@ func (s *SnapshotFull) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
 }
 
 func (s *SnapshotFull) Close() error {
+	if err := s.snapshot.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
 	return nil
 }
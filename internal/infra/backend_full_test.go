@@ -0,0 +1,101 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFullLoadCopiesValues checks that the values loaded by the full snapshot
+// are not overwritten when the iterator moves to the next blocks.
+func TestFullLoadCopiesValues(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackend(path)
+	require.NoError(t, err)
+
+	const count = 20000
+
+	// a padding makes the values span more blocks than the cache holds
+	email := func(i int) string { return fmt.Sprintf("person%06d@example.org%s", i, strings.Repeat("x", 400)) }
+
+	for i := 0; i < count; i++ {
+		key := silo.DataNode{Key: "ID", Data: fmt.Sprintf("%06d", i)}
+		value := silo.DataNode{Key: "EMAIL", Data: email(i)}
+		require.NoError(t, backend.Store(key, value))
+	}
+
+	// reopen so the nodes are read from tables instead of the memtable
+	require.NoError(t, backend.Close())
+
+	full, err := infra.NewBackendFull(path)
+	require.NoError(t, err)
+
+	defer full.Close()
+
+	snapshot := full.Snapshot()
+	defer snapshot.Close()
+
+	_, has, err := snapshot.Next()
+	require.NoError(t, err)
+	require.True(t, has)
+
+	for i := 0; i < count; i++ {
+		key := silo.DataNode{Key: "ID", Data: fmt.Sprintf("%06d", i)}
+
+		values, err := snapshot.PullAll(key)
+		require.NoError(t, err)
+		assert.Equal(t, []silo.DataNode{{Key: "EMAIL", Data: email(i)}}, values)
+	}
+}
+
+// TestFullPullAllBeforeNext checks that the full snapshot loads the nodes when
+// the first call is a pull instead of a move to the next node.
+func TestFullPullAllBeforeNext(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackendFull(filepath.Join(t.TempDir(), "silo"))
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	key := silo.DataNode{Key: "ID", Data: "0001"}
+	value := silo.DataNode{Key: "EMAIL", Data: "person@example.org"}
+	require.NoError(t, backend.Store(key, value))
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	values, err := snapshot.PullAll(key)
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{value}, values)
+
+	_, has, err := snapshot.Next()
+	require.NoError(t, err)
+	assert.False(t, has)
+}
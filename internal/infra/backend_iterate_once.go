@@ -42,48 +42,38 @@ func (b BackendInterateOnce) Snapshot() silo.Snapshot { //nolint:ireturn
 	return NewSnapshotInterateOnce(b.db)
 }
 
+// SnapshotInterateOnce reads links from a pebble snapshot with a single ordered iteration over the nodes.
 type SnapshotInterateOnce struct {
-	db     *pebble.DB
-	iter   *pebble.Iterator
-	pulled map[string]bool
+	snapshot *pebble.Snapshot
+	iter     *pebble.Iterator
+	pulled   map[string]bool
 }
 
 const DefaultPulledMapCap = 128
 
 func NewSnapshotInterateOnce(db *pebble.DB) silo.Snapshot { //nolint:ireturn
-	return SnapshotInterateOnce{
-		db:     db,
-		iter:   nil,
-		pulled: make(map[string]bool, DefaultPulledMapCap),
+	return &SnapshotInterateOnce{
+		snapshot: db.NewSnapshot(),
+		iter:     nil,
+		pulled:   make(map[string]bool, DefaultPulledMapCap),
 	}
 }
 
-func (s SnapshotInterateOnce) Next() (silo.DataNode, bool, error) {
-	if s.iter == nil { //nolint:nestif
+func (s *SnapshotInterateOnce) Next() (silo.DataNode, bool, error) {
+	var valid bool
+
+	if s.iter == nil {
 		var err error
-		if s.iter, err = s.db.NewIter(&pebble.IterOptions{}); err != nil { //nolint:exhaustruct
+		if s.iter, err = s.snapshot.NewIter(nodesIterOptions()); err != nil {
 			return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
 		}
 
-		if !s.iter.First() {
-			return silo.DataNode{Key: "", Data: ""}, false, nil
-		}
-
-		if _, pulled := s.pulled[string(s.iter.Key())]; !pulled {
-			node, err := decodeKey(s.iter.Key())
-			if err != nil {
-				return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
-			}
-
-			return node, true, nil
-		}
+		valid = s.iter.First()
+	} else {
+		valid = s.iter.Next()
 	}
 
-	for {
-		if !s.iter.Next() {
-			return silo.DataNode{Key: "", Data: ""}, false, nil
-		}
-
+	for ; valid; valid = s.iter.Next() {
 		if _, pulled := s.pulled[string(s.iter.Key())]; !pulled {
 			node, err := decodeKey(s.iter.Key())
 			if err != nil {
@@ -93,9 +83,11 @@ func (s SnapshotInterateOnce) Next() (silo.DataNode, bool, error) {
 			return node, true, nil
 		}
 	}
+
+	return silo.DataNode{Key: "", Data: ""}, false, nil
 }
 
-func (s SnapshotInterateOnce) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+func (s *SnapshotInterateOnce) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
 	key, err := node.Binary()
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
@@ -107,7 +99,7 @@ func (s SnapshotInterateOnce) PullAll(node silo.DataNode) ([]silo.DataNode, erro
 
 	s.pulled[string(key)] = true
 
-	item, closer, err := s.db.Get(key)
+	item, closer, err := s.snapshot.Get(key)
 	if errors.Is(err, pebble.ErrNotFound) {
 		return []silo.DataNode{}, nil
 	} else if err != nil {
@@ -123,12 +115,14 @@ func (s SnapshotInterateOnce) PullAll(node silo.DataNode) ([]silo.DataNode, erro
 	return set, nil
 }
 
-func (s SnapshotInterateOnce) Close() error {
-	if s.iter == nil {
-		return nil
+func (s *SnapshotInterateOnce) Close() error {
+	if s.iter != nil {
+		if err := s.iter.Close(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
 	}
 
-	if err := s.iter.Close(); err != nil {
+	if err := s.snapshot.Close(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
@@ -0,0 +1,541 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/rs/zerolog/log"
+)
+
+// shardQueueSize is the number of writes waiting for a shard before Store blocks.
+const shardQueueSize = 1024
+
+// shardWrite is a store queued for a shard, or a barrier when done is not nil.
+type shardWrite struct {
+	key    silo.DataNode
+	values []silo.DataNode
+	done   chan error
+}
+
+// shard is a pebble database holding the nodes whose hash selects it, with the goroutine writing its queue.
+type shard struct {
+	Storage
+	queue  chan shardWrite
+	failed *atomic.Pointer[error]
+}
+
+// run writes the queue of the shard until it is closed, the first error stops the writes, is reported to every
+// following barrier and is kept in failed if no other shard failed before.
+func (s *shard) run() {
+	var err error
+
+	for write := range s.queue {
+		switch {
+		case write.done != nil:
+			write.done <- err
+		case err == nil:
+			if err = storeAll(s.Storage, write.key, write.values...); err != nil {
+				s.failed.CompareAndSwap(nil, &err)
+			}
+		}
+	}
+}
+
+// BackendSharded partitions nodes by hash across the pebble databases stored in the shard-NNN directories of its path.
+type BackendSharded struct {
+	shards []*shard
+	done   chan struct{}
+	failed atomic.Pointer[error]
+}
+
+// NewBackendSharded opens the sharded silo stored in path, or creates it with the given number of shards.
+func NewBackendSharded(path string, shards int) (*BackendSharded, error) {
+	return openShardedBackend(path, url.Values{"shards": {strconv.Itoa(shards)}}, false)
+}
+
+// openSharded opens a sharded silo, the shards parameter sets the number of shards of a new silo (default is the
+// number of CPUs), other parameters are given to the pebble database of each shard.
+func openSharded(location string, params url.Values, readOnly bool) (Storage, error) { //nolint:ireturn
+	return openShardedBackend(location, params, readOnly)
+}
+
+func openShardedBackend(location string, params url.Values, readOnly bool) (*BackendSharded, error) {
+	count, err := shardCount(location, params.Get("shards"))
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &BackendSharded{shards: make([]*shard, 0, count), done: make(chan struct{}, count)} //nolint:exhaustruct
+
+	for i := 0; i < count; i++ {
+		storage, err := openPebbleShare(filepath.Join(location, shardName(i)), params, readOnly, count)
+		if err != nil {
+			return nil, errors.Join(err, backend.Close())
+		}
+
+		shard := &shard{Storage: storage, queue: make(chan shardWrite, shardQueueSize), failed: &backend.failed}
+		backend.shards = append(backend.shards, shard)
+
+		go func() {
+			shard.run()
+			backend.done <- struct{}{}
+		}()
+	}
+
+	if params.Get("snapshot") == "auto" {
+		strategies := make([]string, 0, count)
+		for _, shard := range backend.shards {
+			strategies = append(strategies, strategyOf(shard.Storage))
+		}
+
+		log.Info().Int("shards", count).Strs("strategies", strategies).Msg("dump strategy selected")
+	}
+
+	return backend, nil
+}
+
+func shardName(index int) string {
+	return fmt.Sprintf("shard-%03d", index)
+}
+
+// shardCount returns the number of shards of the silo stored in location, or the requested number of shards of a
+// new silo.
+func shardCount(location string, requested string) (int, error) {
+	existing := 0
+
+	for ; ; existing++ {
+		if _, err := os.Stat(filepath.Join(location, shardName(existing))); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	if existing == 0 {
+		if err := checkEmpty(location); err != nil {
+			return 0, err
+		}
+	}
+
+	if requested == "" {
+		if existing > 0 {
+			return existing, nil
+		}
+
+		return runtime.NumCPU(), nil
+	}
+
+	count, err := strconv.Atoi(requested)
+	if err != nil || count < 1 {
+		return 0, fmt.Errorf("%w : invalid number of shards %q", ErrInvalidBackendURI, requested)
+	}
+
+	if existing > 0 && count != existing {
+		return 0, fmt.Errorf("%w : the silo has %d shards, not %d", ErrInvalidBackendURI, existing, count)
+	}
+
+	return count, nil
+}
+
+// checkEmpty fails if location exists and is not an empty directory, so a sharded silo is not created inside
+// another silo.
+func checkEmpty(location string) error {
+	entries, err := os.ReadDir(location)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil || len(entries) > 0 {
+		return fmt.Errorf("%w '%s'", ErrPathIsNotValid, location)
+	}
+
+	return nil
+}
+
+// shardIndex selects the shard of a node from the FNV-1a hash of its encoding.
+func shardIndex(node silo.DataNode, count int) (int, error) {
+	key, err := node.Binary()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write(key)
+
+	return int(hash.Sum32() % uint32(count)), nil
+}
+
+func (b *BackendSharded) shardOf(node silo.DataNode) (*shard, error) {
+	index, err := shardIndex(node, len(b.shards))
+	if err != nil {
+		return nil, err
+	}
+
+	return b.shards[index], nil
+}
+
+// flush waits for the writes queued for the given shards, and returns their errors.
+func (b *BackendSharded) flush(shards ...*shard) error {
+	barriers := make([]chan error, len(shards))
+
+	for i, shard := range shards {
+		barriers[i] = make(chan error, 1)
+		shard.queue <- shardWrite{key: silo.DataNode{Key: "", Data: nil}, values: nil, done: barriers[i]}
+	}
+
+	errs := make([]error, 0, len(shards))
+	for _, barrier := range barriers {
+		errs = append(errs, <-barrier)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func (b *BackendSharded) Store(key silo.DataNode, value silo.DataNode) error {
+	return b.StoreAll(key, value)
+}
+
+// StoreAll queues the values for the shard of key, the error of a failed write is returned by the following calls.
+func (b *BackendSharded) StoreAll(key silo.DataNode, values ...silo.DataNode) error {
+	if failed := b.failed.Load(); failed != nil {
+		return fmt.Errorf("%w", *failed)
+	}
+
+	shard, err := b.shardOf(key)
+	if err != nil {
+		return err
+	}
+
+	shard.queue <- shardWrite{key: key, values: append([]silo.DataNode{}, values...), done: nil}
+
+	return nil
+}
+
+func (b *BackendSharded) Get(key silo.DataNode) ([]silo.DataNode, error) {
+	shard, err := b.shardOf(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.flush(shard); err != nil {
+		return nil, err
+	}
+
+	return shard.Get(key) //nolint:wrapcheck
+}
+
+func (b *BackendSharded) Remove(key silo.DataNode, value silo.DataNode) error {
+	shard, err := b.shardOf(key)
+	if err != nil {
+		return err
+	}
+
+	if err := b.flush(shard); err != nil {
+		return err
+	}
+
+	return shard.Remove(key, value) //nolint:wrapcheck
+}
+
+func (b *BackendSharded) Delete(key silo.DataNode) error {
+	shard, err := b.shardOf(key)
+	if err != nil {
+		return err
+	}
+
+	if err := b.flush(shard); err != nil {
+		return err
+	}
+
+	return shard.Delete(key) //nolint:wrapcheck
+}
+
+// ForEach calls fn with each node and its set, shard by shard.
+func (b *BackendSharded) ForEach(fn func(key silo.DataNode, values []silo.DataNode) error) error {
+	if err := b.flush(b.shards...); err != nil {
+		return err
+	}
+
+	for _, shard := range b.shards {
+		if err := shard.ForEach(fn); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// Metadata of the silo are stored in the first shard.
+func (b *BackendSharded) Metadata() (silo.Metadata, error) {
+	return b.shards[0].Metadata() //nolint:wrapcheck
+}
+
+// syncer is implemented by backends able to write to disk the updates committed without sync.
+type syncer interface {
+	Sync() error
+}
+
+// sync waits for queued writes and syncs every shard, so that metadata written next only cover updates on disk.
+func (b *BackendSharded) sync() error {
+	if err := b.flush(b.shards...); err != nil {
+		return err
+	}
+
+	for _, shard := range b.shards {
+		if syncer, ok := shard.Storage.(syncer); ok {
+			if err := syncer.Sync(); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetMetadata syncs all shards first, so a checkpoint only covers rows written to all shards.
+func (b *BackendSharded) SetMetadata(metadata silo.Metadata) error {
+	if err := b.sync(); err != nil {
+		return err
+	}
+
+	return b.shards[0].SetMetadata(metadata) //nolint:wrapcheck
+}
+
+// AppendMetadata adds the scan records and the checkpoints of other to the metadata of the silo.
+func (b *BackendSharded) AppendMetadata(other silo.Metadata) error {
+	if err := b.sync(); err != nil {
+		return err
+	}
+
+	return appendMetadata(b.shards[0].Storage, other)
+}
+
+func (b *BackendSharded) tracker(shard *shard) (silo.ChangeTracker, error) { //nolint:ireturn
+	tracker, ok := shard.Storage.(silo.ChangeTracker)
+	if !ok {
+		return nil, silo.ErrChangesNotTracked
+	}
+
+	return tracker, nil
+}
+
+func (b *BackendSharded) Changes() ([]silo.Change, error) {
+	if err := b.flush(b.shards...); err != nil {
+		return nil, err
+	}
+
+	changes := []silo.Change{}
+
+	for _, shard := range b.shards {
+		tracker, err := b.tracker(shard)
+		if err != nil {
+			return nil, err
+		}
+
+		shardChanges, err := tracker.Changes()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		changes = append(changes, shardChanges...)
+	}
+
+	return changes, nil
+}
+
+// Untouch forgets the changes in the shard of their node, a removal is forgotten in the shards where it is recorded.
+func (b *BackendSharded) Untouch(changes ...silo.Change) error {
+	groups := make([][]silo.Change, len(b.shards))
+
+	for _, change := range changes {
+		if change.Removed != "" {
+			for index := range groups {
+				groups[index] = append(groups[index], change)
+			}
+
+			continue
+		}
+
+		index, err := shardIndex(change.Node, len(b.shards))
+		if err != nil {
+			return err
+		}
+
+		groups[index] = append(groups[index], change)
+	}
+
+	for index, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		tracker, err := b.tracker(b.shards[index])
+		if err != nil {
+			return err
+		}
+
+		if err := tracker.Untouch(group...); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (b *BackendSharded) EntityOf(node silo.DataNode) (string, bool, error) {
+	shard, err := b.shardOf(node)
+	if err != nil {
+		return "", false, err
+	}
+
+	tracker, err := b.tracker(shard)
+	if err != nil {
+		return "", false, err
+	}
+
+	return tracker.EntityOf(node) //nolint:wrapcheck
+}
+
+func (b *BackendSharded) SetEntity(uuid string, nodes ...silo.DataNode) error {
+	groups := make([][]silo.DataNode, len(b.shards))
+
+	for _, node := range nodes {
+		index, err := shardIndex(node, len(b.shards))
+		if err != nil {
+			return err
+		}
+
+		groups[index] = append(groups[index], node)
+	}
+
+	for index, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		tracker, err := b.tracker(b.shards[index])
+		if err != nil {
+			return err
+		}
+
+		if err := tracker.SetEntity(uuid, group...); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// Snapshot waits for queued writes and takes a snapshot of each shard.
+func (b *BackendSharded) Snapshot() silo.Snapshot { //nolint:ireturn
+	snapshot := &SnapshotSharded{snapshots: make([]silo.Snapshot, 0, len(b.shards)), current: 0, err: nil}
+
+	if snapshot.err = b.flush(b.shards...); snapshot.err != nil {
+		return snapshot
+	}
+
+	for _, shard := range b.shards {
+		snapshot.snapshots = append(snapshot.snapshots, shard.Snapshot())
+	}
+
+	return snapshot
+}
+
+// Close waits for queued writes, stops the goroutines writing the shards and closes them.
+func (b *BackendSharded) Close() error {
+	errs := []error{b.flush(b.shards...)}
+
+	for _, shard := range b.shards {
+		close(shard.queue)
+	}
+
+	for range b.shards {
+		<-b.done
+	}
+
+	for _, shard := range b.shards {
+		errs = append(errs, shard.Close())
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// SnapshotSharded iterates over the nodes of each shard in turn, and pulls each node from its own shard.
+type SnapshotSharded struct {
+	snapshots []silo.Snapshot
+	current   int
+	err       error
+}
+
+func (s *SnapshotSharded) Next() (silo.DataNode, bool, error) {
+	if s.err != nil {
+		return silo.DataNode{Key: "", Data: ""}, false, s.err
+	}
+
+	for ; s.current < len(s.snapshots); s.current++ {
+		node, hasNext, err := s.snapshots[s.current].Next()
+		if err != nil || hasNext {
+			return node, hasNext, err //nolint:wrapcheck
+		}
+	}
+
+	return silo.DataNode{Key: "", Data: ""}, false, nil
+}
+
+func (s *SnapshotSharded) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	index, err := shardIndex(node, len(s.snapshots))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.snapshots[index].PullAll(node) //nolint:wrapcheck
+}
+
+func (s *SnapshotSharded) Close() error {
+	errs := make([]error, 0, len(s.snapshots))
+
+	for _, snapshot := range s.snapshots {
+		errs = append(errs, snapshot.Close())
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,548 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cockroachdb/pebble"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMemoryBudget is the memory used by the spill strategy to remember visited nodes and to buffer the entity being
+// dumped before using the disk.
+const DefaultMemoryBudget = 64 << 20
+
+// visitedEntryOverhead estimates the memory used by a map entry besides its key.
+const visitedEntryOverhead = 48
+
+// Prefixes of the keys of the temporary database, one for the visited nodes and one for each part of the buffer.
+const (
+	spillVisited  byte = 'v'
+	spillAdded    byte = 'a'
+	spillNodes    byte = 'n'
+	spillFrontier byte = 'f'
+)
+
+// BackendSpill dumps within a memory budget, beyond which the visited nodes and the entity being dumped spill to a
+// temporary pebble database.
+type BackendSpill struct {
+	Backend
+	budget int
+}
+
+func NewBackendSpill(path string, budget int) (BackendSpill, error) {
+	backend, err := NewBackend(path)
+	if err != nil {
+		return BackendSpill{backend, budget}, err
+	}
+
+	return BackendSpill{backend, budget}, nil
+}
+
+func (b BackendSpill) Snapshot() silo.Snapshot { //nolint:ireturn
+	return NewSnapshotSpill(b.db, b.budget)
+}
+
+// SnapshotSpill is a SnapshotInterateOnce whose visited nodes and entity buffer spill to disk beyond the memory budget,
+// half of the budget is used by each.
+type SnapshotSpill struct {
+	snapshot *pebble.Snapshot
+	iter     *pebble.Iterator
+	disk     *spillDatabase
+	visited  *visitedSet
+	buffer   *entityBufferSpill
+}
+
+func NewSnapshotSpill(db *pebble.DB, budget int) silo.Snapshot { //nolint:ireturn
+	disk := &spillDatabase{db: nil, dir: "", budget: budget}
+
+	return &SnapshotSpill{
+		snapshot: db.NewSnapshot(),
+		iter:     nil,
+		disk:     disk,
+		visited:  newVisitedSet(disk, budget/2),        //nolint:gomnd
+		buffer:   newEntityBufferSpill(disk, budget/2), //nolint:gomnd
+	}
+}
+
+func (s *SnapshotSpill) Next() (silo.DataNode, bool, error) {
+	var valid bool
+
+	if s.iter == nil {
+		var err error
+		if s.iter, err = s.snapshot.NewIter(nodesIterOptions()); err != nil {
+			return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+		}
+
+		valid = s.iter.First()
+	} else {
+		valid = s.iter.Next()
+	}
+
+	for ; valid; valid = s.iter.Next() {
+		visited, err := s.visited.has(s.iter.Key())
+		if err != nil {
+			return silo.DataNode{Key: "", Data: ""}, false, err
+		}
+
+		if !visited {
+			node, err := decodeKey(s.iter.Key())
+			if err != nil {
+				return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+			}
+
+			return node, true, nil
+		}
+	}
+
+	return silo.DataNode{Key: "", Data: ""}, false, nil
+}
+
+func (s *SnapshotSpill) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+	key, err := node.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if visited, err := s.visited.has(key); err != nil || visited {
+		return []silo.DataNode{}, err
+	}
+
+	if err := s.visited.add(key); err != nil {
+		return nil, err
+	}
+
+	item, closer, err := s.snapshot.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return []silo.DataNode{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer closer.Close()
+
+	set, err := decode(item)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return set, nil
+}
+
+// Buffer returns the buffer of the entities dumped from this snapshot, its temporary data is removed with the snapshot.
+func (s *SnapshotSpill) Buffer() silo.EntityBuffer { //nolint:ireturn
+	return s.buffer
+}
+
+func (s *SnapshotSpill) Close() error {
+	errs := []error{s.disk.close()}
+
+	if s.iter != nil {
+		errs = append(errs, s.iter.Close())
+	}
+
+	errs = append(errs, s.snapshot.Close())
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// spillDatabase is the temporary pebble database of a snapshot, created on first use and removed when closed.
+type spillDatabase struct {
+	db     *pebble.DB
+	dir    string
+	budget int
+}
+
+func (d *spillDatabase) open() (*pebble.DB, error) {
+	if d.db != nil {
+		return d.db, nil
+	}
+
+	dir, err := os.MkdirTemp("", "silo-dump-")
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// the temporary database is small and short-lived, its memory is also bounded by the budget
+	options := &pebble.Options{ //nolint:exhaustruct
+		Logger:       BackendLogger{},
+		MemTableSize: uint64(max(d.budget/4, 1<<20)), //nolint:gomnd
+	}
+
+	if d.db, err = pebble.Open(dir, options); err != nil {
+		os.RemoveAll(dir)
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	d.dir = dir
+
+	log.Info().Str("path", dir).Int("budget", d.budget).Msg("dump memory budget exceeded, spilling to disk")
+
+	return d.db, nil
+}
+
+// has returns true if key is stored with prefix, it does not create the database.
+func (d *spillDatabase) has(prefix byte, key []byte) (bool, error) {
+	if d.db == nil {
+		return false, nil
+	}
+
+	_, closer, err := d.db.Get(append([]byte{prefix}, key...))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	closer.Close()
+
+	return true, nil
+}
+
+// clear deletes the keys stored with the given prefixes.
+func (d *spillDatabase) clear(prefixes ...byte) error {
+	if d.db == nil {
+		return nil
+	}
+
+	for _, prefix := range prefixes {
+		if err := d.db.DeleteRange([]byte{prefix}, []byte{prefix + 1}, pebble.NoSync); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *spillDatabase) close() error {
+	if d.db == nil {
+		return nil
+	}
+
+	err := d.db.Close()
+	d.db = nil
+
+	return errors.Join(err, os.RemoveAll(d.dir))
+}
+
+// visitedSet records keys in memory up to a budget, then moves them to the temporary database.
+type visitedSet struct {
+	memory map[string]bool
+	size   int
+	budget int
+	disk   *spillDatabase
+}
+
+func newVisitedSet(disk *spillDatabase, budget int) *visitedSet {
+	return &visitedSet{memory: map[string]bool{}, size: 0, budget: budget, disk: disk}
+}
+
+func (v *visitedSet) add(key []byte) error {
+	v.memory[string(key)] = true
+	v.size += len(key) + visitedEntryOverhead
+
+	if v.size > v.budget {
+		return v.spill()
+	}
+
+	return nil
+}
+
+func (v *visitedSet) has(key []byte) (bool, error) {
+	if v.memory[string(key)] {
+		return true, nil
+	}
+
+	return v.disk.has(spillVisited, key)
+}
+
+// spill moves the keys held in memory to the temporary database.
+func (v *visitedSet) spill() error {
+	db, err := v.disk.open()
+	if err != nil {
+		return err
+	}
+
+	batch := db.NewBatch()
+	defer batch.Close()
+
+	for key := range v.memory {
+		if err := batch.Set(append([]byte{spillVisited}, key...), nil, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	v.memory = map[string]bool{}
+	v.size = 0
+
+	return nil
+}
+
+// entityBufferSpill holds the nodes of an entity and the nodes left to visit in memory up to a budget, then moves
+// them to the temporary database. The nodes spilled are the oldest ones, stored by position in their list.
+type entityBufferSpill struct {
+	added           map[string]bool
+	nodes           [][]byte
+	frontier        [][]byte
+	spilledNodes    uint64
+	spilledFrontier uint64
+	spilled         bool
+	size            int
+	budget          int
+	disk            *spillDatabase
+}
+
+func newEntityBufferSpill(disk *spillDatabase, budget int) *entityBufferSpill {
+	return &entityBufferSpill{
+		added:           map[string]bool{},
+		nodes:           [][]byte{},
+		frontier:        [][]byte{},
+		spilledNodes:    0,
+		spilledFrontier: 0,
+		spilled:         false,
+		size:            0,
+		budget:          budget,
+		disk:            disk,
+	}
+}
+
+func (b *entityBufferSpill) Add(node silo.DataNode) (bool, error) {
+	key, err := node.Binary()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	if b.added[string(key)] {
+		return false, nil
+	}
+
+	if b.spilled {
+		if added, err := b.disk.has(spillAdded, key); err != nil || added {
+			return false, err
+		}
+	}
+
+	b.added[string(key)] = true
+	b.nodes = append(b.nodes, key)
+	b.size += 2*len(key) + visitedEntryOverhead
+
+	return true, b.spillOver()
+}
+
+func (b *entityBufferSpill) ForEach(fn func(node silo.DataNode) error) error {
+	if b.spilledNodes > 0 {
+		if err := b.forEachSpilled(fn); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range b.nodes {
+		node, err := decodeKey(key)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := fn(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *entityBufferSpill) forEachSpilled(fn func(node silo.DataNode) error) error {
+	iter, err := b.disk.db.NewIter(&pebble.IterOptions{ //nolint:exhaustruct
+		LowerBound: []byte{spillNodes},
+		UpperBound: []byte{spillNodes + 1},
+	})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer iter.Close()
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		node, err := decodeKey(iter.Value())
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := fn(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *entityBufferSpill) Push(node silo.DataNode) error {
+	key, err := node.Binary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.frontier = append(b.frontier, key)
+	b.size += len(key)
+
+	return b.spillOver()
+}
+
+func (b *entityBufferSpill) Pop() (silo.DataNode, bool, error) {
+	if len(b.frontier) == 0 && b.spilledFrontier > 0 {
+		if err := b.unspillFrontier(); err != nil {
+			return silo.DataNode{Key: "", Data: nil}, false, err
+		}
+	}
+
+	if len(b.frontier) == 0 {
+		return silo.DataNode{Key: "", Data: nil}, false, nil
+	}
+
+	key := b.frontier[len(b.frontier)-1]
+	b.frontier = b.frontier[:len(b.frontier)-1]
+	b.size -= len(key)
+
+	node, err := decodeKey(key)
+	if err != nil {
+		return silo.DataNode{Key: "", Data: nil}, false, fmt.Errorf("%w", err)
+	}
+
+	return node, true, nil
+}
+
+// unspillFrontier moves the top of the frontier stored on disk back to memory, within the budget.
+func (b *entityBufferSpill) unspillFrontier() error {
+	iter, err := b.disk.db.NewIter(&pebble.IterOptions{ //nolint:exhaustruct
+		LowerBound: []byte{spillFrontier},
+		UpperBound: spillPosition(spillFrontier, b.spilledFrontier),
+	})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer iter.Close()
+
+	loaded := [][]byte{}
+	size := 0
+
+	for valid := iter.Last(); valid && (size <= b.budget/2 || len(loaded) == 0); valid = iter.Prev() {
+		loaded = append(loaded, append([]byte{}, iter.Value()...))
+		size += len(iter.Value())
+	}
+
+	from := b.spilledFrontier - uint64(len(loaded))
+	if err := b.disk.db.DeleteRange(spillPosition(spillFrontier, from), []byte{spillFrontier + 1},
+		pebble.NoSync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for i := len(loaded) - 1; i >= 0; i-- {
+		b.frontier = append(b.frontier, loaded[i])
+	}
+
+	b.spilledFrontier = from
+	b.size += size
+
+	return nil
+}
+
+// spillOver moves the added nodes, the nodes and the frontier held in memory to disk, if over the budget.
+func (b *entityBufferSpill) spillOver() error {
+	if b.size <= b.budget {
+		return nil
+	}
+
+	db, err := b.disk.open()
+	if err != nil {
+		return err
+	}
+
+	batch := db.NewBatch()
+	defer batch.Close()
+
+	for key := range b.added {
+		if err := batch.Set(append([]byte{spillAdded}, key...), nil, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	for i, key := range b.nodes {
+		if err := batch.Set(spillPosition(spillNodes, b.spilledNodes+uint64(i)), key, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	for i, key := range b.frontier {
+		if err := batch.Set(spillPosition(spillFrontier, b.spilledFrontier+uint64(i)), key, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.spilledNodes += uint64(len(b.nodes))
+	b.spilledFrontier += uint64(len(b.frontier))
+	b.spilled = true
+	b.added = map[string]bool{}
+	b.nodes = [][]byte{}
+	b.frontier = [][]byte{}
+	b.size = 0
+
+	return nil
+}
+
+func (b *entityBufferSpill) Reset() error {
+	b.added = map[string]bool{}
+	b.nodes = [][]byte{}
+	b.frontier = [][]byte{}
+	b.spilledNodes = 0
+	b.spilledFrontier = 0
+	b.size = 0
+
+	// the nodes of a previous entity that did not fit in memory are removed from disk
+	if !b.spilled {
+		return nil
+	}
+
+	b.spilled = false
+
+	return b.disk.clear(spillAdded, spillNodes, spillFrontier)
+}
+
+// Close does nothing, the temporary database is removed when the snapshot is closed.
+func (b *entityBufferSpill) Close() error {
+	return nil
+}
+
+// spillPosition is the key of the node at position in a list spilled with prefix, ordered by position.
+func spillPosition(prefix byte, position uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{prefix}, position)
+}
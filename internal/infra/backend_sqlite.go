@@ -0,0 +1,817 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	_ "modernc.org/sqlite" // pure Go driver, registered as "sqlite"
+)
+
+// sqliteSchema stores each node once with a readable rendering of its key, kind and value, next to its binary
+// encoding used to find it. Edges are stored in both directions like the sets of the pebble backend.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS nodes (
+	id    INTEGER PRIMARY KEY AUTOINCREMENT,
+	key   TEXT NOT NULL,
+	kind  TEXT NOT NULL,
+	value TEXT NOT NULL,
+	raw   BLOB NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS edges (
+	source INTEGER NOT NULL REFERENCES nodes (id),
+	target INTEGER NOT NULL REFERENCES nodes (id),
+	PRIMARY KEY (source, target)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS edges_target ON edges (target);
+CREATE TABLE IF NOT EXISTS metadata (
+	id      INTEGER PRIMARY KEY CHECK (id = 1),
+	content TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS changes (
+	node    INTEGER PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+	version INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS entities (
+	node INTEGER PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+	uuid TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS removals (
+	uuid    TEXT PRIMARY KEY,
+	version INTEGER NOT NULL
+) WITHOUT ROWID;
+CREATE VIEW IF NOT EXISTS edge_values AS
+	SELECT s.key AS source_key, s.value AS source_value, t.key AS target_key, t.kind AS target_kind,
+		t.value AS target_value
+	FROM edges e JOIN nodes s ON s.id = e.source JOIN nodes t ON t.id = e.target;
+`
+
+// sqliteBatchSize is the number of updates grouped in a write transaction before it is committed.
+const sqliteBatchSize = 1000
+
+// BackendSQLite stores the silo in a SQLite file that can be queried with standard tools.
+type BackendSQLite struct {
+	db      *sql.DB
+	mutex   sync.Mutex
+	tx      *sql.Tx // write transaction of the pending updates, guarded by mutex
+	pending int     // number of updates in tx, guarded by mutex
+	version int64   // version of the last change, guarded by mutex
+}
+
+func NewBackendSQLite(path string) (*BackendSQLite, error) {
+	return openSQLiteFile(path, false)
+}
+
+// NewBackendSQLiteReadOnly opens an existing SQLite silo without allowing any modification.
+func NewBackendSQLiteReadOnly(path string) (*BackendSQLite, error) {
+	return openSQLiteFile(path, true)
+}
+
+func openSQLite(location string, _ url.Values, readOnly bool) (Storage, error) { //nolint:ireturn
+	return openSQLiteFile(location, readOnly)
+}
+
+func openSQLiteFile(path string, readOnly bool) (*BackendSQLite, error) {
+	// the path is escaped, so that a ? or a # in a file name is not read as parameters
+	dsn := url.URL{Scheme: "file", OmitHost: true, Path: path} //nolint:exhaustruct
+
+	dsn.RawQuery = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if readOnly {
+		dsn.RawQuery += "&mode=ro"
+	} else {
+		// deleted content is overwritten, so erased values do not stay in free pages
+		dsn.RawQuery += "&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=secure_delete(1)"
+	}
+
+	database, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	if !readOnly {
+		if _, err := database.Exec(sqliteSchema); err != nil {
+			database.Close()
+
+			return nil, fmt.Errorf("unable to open database %v : %w", path, err)
+		}
+	} else if err := database.Ping(); err != nil {
+		database.Close()
+
+		return nil, fmt.Errorf("unable to open database %v : %w", path, err)
+	}
+
+	return &BackendSQLite{db: database, mutex: sync.Mutex{}, tx: nil, pending: 0, version: initialVersion()}, nil
+}
+
+// write runs fn in the write transaction, within a savepoint so that a failed update is undone alone, and commits
+// the transaction once it groups sqliteBatchSize updates. The mutex must be held.
+func (b *BackendSQLite) write(fn func(tx *sql.Tx) error) error {
+	if b.tx == nil {
+		tx, err := b.db.Begin()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		b.tx = tx
+	}
+
+	if _, err := b.tx.Exec(`SAVEPOINT updating`); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := fn(b.tx); err != nil {
+		if _, rollbackErr := b.tx.Exec(`ROLLBACK TO updating`); rollbackErr != nil {
+			return fmt.Errorf("%w: %w", err, rollbackErr)
+		}
+
+		if _, releaseErr := b.tx.Exec(`RELEASE updating`); releaseErr != nil {
+			return fmt.Errorf("%w: %w", err, releaseErr)
+		}
+
+		return err
+	}
+
+	if _, err := b.tx.Exec(`RELEASE updating`); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.pending++
+
+	if b.pending >= sqliteBatchSize {
+		return b.commit()
+	}
+
+	return nil
+}
+
+// commit commits the write transaction, if any. The mutex must be held.
+func (b *BackendSQLite) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+
+	tx := b.tx
+	b.tx, b.pending = nil, 0
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// flush commits the pending updates, so that they are read out of the write transaction.
+func (b *BackendSQLite) flush() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.commit()
+}
+
+// reader returns the write transaction if it is pending, so that reads see the pending updates. The mutex must be
+// held while reading.
+func (b *BackendSQLite) reader() querier { //nolint:ireturn
+	if b.tx != nil {
+		return b.tx
+	}
+
+	return b.db
+}
+
+// nodeID returns the id of the node, inserting it if needed.
+func nodeID(tx *sql.Tx, node silo.DataNode) (int64, error) {
+	raw, err := node.Binary()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	kind, data := nodeKind(node)
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO nodes (key, kind, value, raw) VALUES (?, ?, ?, ?) ON CONFLICT (raw) DO NOTHING`,
+		node.Key, kind, string(value), raw); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM nodes WHERE raw = ?`, raw).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return id, nil
+}
+
+// nodeKind returns the kind of node rendered in the nodes table, with its value without mark.
+func nodeKind(node silo.DataNode) (string, any) {
+	switch value := node.Data.(type) {
+	case silo.SoftValue:
+		return "soft", value.Value
+	case silo.AttributeValue:
+		return "attribute", value.Value
+	default:
+		return "", node.Data
+	}
+}
+
+// update runs fn in the write transaction, then touches the nodes whose set changed and deletes the unlinked ones.
+func (b *BackendSQLite) update(fn func(tx *sql.Tx) (changed []int64, unlinked []int64, err error)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *sql.Tx) error {
+		changed, unlinked, err := fn(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range changed {
+			if err := b.touch(tx, id); err != nil {
+				return err
+			}
+		}
+
+		// changes and entities of deleted nodes are removed by cascade
+		for _, id := range append(changed, unlinked...) {
+			if _, err := tx.Exec(`DELETE FROM nodes WHERE id = ? AND NOT EXISTS (SELECT 1 FROM edges WHERE source = ?)
+				AND NOT EXISTS (SELECT 1 FROM edges WHERE target = ?)`, id, id, id); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// touch marks the node as changed, or erases its change and its entity if it has no set left, like Backend.erase.
+func (b *BackendSQLite) touch(tx *sql.Tx, id int64) error {
+	var linked bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM edges WHERE source = ?)`, id).Scan(&linked); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.version++
+
+	if !linked {
+		if _, err := tx.Exec(`INSERT INTO removals (uuid, version) SELECT uuid, ? FROM entities WHERE node = ?
+			ON CONFLICT (uuid) DO UPDATE SET version = excluded.version`, b.version, id); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		for _, query := range []string{`DELETE FROM changes WHERE node = ?`, `DELETE FROM entities WHERE node = ?`} {
+			if _, err := tx.Exec(query, id); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	}
+
+	if _, err := tx.Exec(`INSERT INTO changes (node, version) VALUES (?, ?)
+		ON CONFLICT (node) DO UPDATE SET version = excluded.version`, id, b.version); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func (b *BackendSQLite) Store(key silo.DataNode, value silo.DataNode) error {
+	return b.StoreAll(key, value)
+}
+
+// StoreAll adds all values to the set of key in a single transaction.
+func (b *BackendSQLite) StoreAll(key silo.DataNode, values ...silo.DataNode) error {
+	return b.update(func(tx *sql.Tx) ([]int64, []int64, error) {
+		source, err := nodeID(tx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, value := range values {
+			target, err := nodeID(tx, value)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if _, err := tx.Exec(`INSERT INTO edges (source, target) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+				source, target); err != nil {
+				return nil, nil, fmt.Errorf("%w", err)
+			}
+		}
+
+		return []int64{source}, nil, nil
+	})
+}
+
+func (b *BackendSQLite) Get(key silo.DataNode) ([]silo.DataNode, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return querySet(b.reader(), key)
+}
+
+// querier is implemented by sql.DB and sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func querySet(db querier, key silo.DataNode) ([]silo.DataNode, error) {
+	raw, err := key.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	rows, err := db.Query(`SELECT t.raw FROM nodes s JOIN edges e ON e.source = s.id JOIN nodes t ON t.id = e.target
+		WHERE s.raw = ?`, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	defer rows.Close()
+
+	nodes := []silo.DataNode{}
+
+	for rows.Next() {
+		var target []byte
+		if err := rows.Scan(&target); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		node, err := silo.DecodeDataNode(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return nodes, nil
+}
+
+// existingNodeID returns the id of the node, or false if the node is not stored.
+func existingNodeID(tx *sql.Tx, node silo.DataNode) (int64, bool, error) {
+	raw, err := node.Binary()
+	if err != nil {
+		return 0, false, fmt.Errorf("%w", err)
+	}
+
+	var id int64
+
+	err = tx.QueryRow(`SELECT id FROM nodes WHERE raw = ?`, raw).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("%w", err)
+	}
+
+	return id, true, nil
+}
+
+func (b *BackendSQLite) Remove(key silo.DataNode, value silo.DataNode) error {
+	return b.update(func(tx *sql.Tx) ([]int64, []int64, error) {
+		source, found, err := existingNodeID(tx, key)
+		if err != nil || !found {
+			return nil, nil, err
+		}
+
+		target, found, err := existingNodeID(tx, value)
+		if err != nil || !found {
+			return nil, nil, err
+		}
+
+		result, err := tx.Exec(`DELETE FROM edges WHERE source = ? AND target = ?`, source, target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w", err)
+		}
+
+		if removed, err := result.RowsAffected(); err != nil || removed == 0 {
+			return nil, nil, err //nolint:wrapcheck
+		}
+
+		return []int64{source}, []int64{target}, nil
+	})
+}
+
+func (b *BackendSQLite) Delete(key silo.DataNode) error {
+	return b.update(func(tx *sql.Tx) ([]int64, []int64, error) {
+		source, found, err := existingNodeID(tx, key)
+		if err != nil || !found {
+			return nil, nil, err
+		}
+
+		rows, err := tx.Query(`DELETE FROM edges WHERE source = ? RETURNING target`, source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w", err)
+		}
+
+		defer rows.Close()
+
+		targets := []int64{}
+
+		for rows.Next() {
+			var target int64
+			if err := rows.Scan(&target); err != nil {
+				return nil, nil, fmt.Errorf("%w", err)
+			}
+
+			targets = append(targets, target)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("%w", err)
+		}
+
+		return []int64{source}, targets, nil
+	})
+}
+
+// ForEach calls fn with each node and its set.
+func (b *BackendSQLite) ForEach(fn func(key silo.DataNode, values []silo.DataNode) error) error {
+	// fn can update the silo, so the rows are read out of the write transaction
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	rows, err := b.db.Query(`SELECT s.raw, t.raw FROM edges e JOIN nodes s ON s.id = e.source
+		JOIN nodes t ON t.id = e.target ORDER BY e.source`)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer rows.Close()
+
+	var (
+		current []byte
+		key     silo.DataNode
+		values  []silo.DataNode
+	)
+
+	for rows.Next() {
+		var source, target []byte
+		if err := rows.Scan(&source, &target); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if string(source) != string(current) {
+			if current != nil {
+				if err := fn(key, values); err != nil {
+					return err
+				}
+			}
+
+			if key, err = silo.DecodeDataNode(source); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			current, values = source, []silo.DataNode{}
+		}
+
+		value, err := silo.DecodeDataNode(target)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if current != nil {
+		return fn(key, values)
+	}
+
+	return nil
+}
+
+// Metadata returns the metadata of the silo, with no scan if the silo has no metadata.
+func (b *BackendSQLite) Metadata() (silo.Metadata, error) {
+	metadata := silo.Metadata{Scans: []silo.ScanRecord{}, Checkpoints: map[string]silo.Checkpoint{}, Compacted: false}
+
+	var content string
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.reader().QueryRow(`SELECT content FROM metadata WHERE id = 1`).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return metadata, nil
+	} else if err != nil {
+		return metadata, fmt.Errorf("%w", err)
+	}
+
+	if err := json.Unmarshal([]byte(content), &metadata); err != nil {
+		return metadata, fmt.Errorf("%w", err)
+	}
+
+	return metadata, nil
+}
+
+func (b *BackendSQLite) SetMetadata(metadata silo.Metadata) error {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = b.write(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO metadata (id, content) VALUES (1, ?)
+			ON CONFLICT (id) DO UPDATE SET content = excluded.content`, string(content)); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// a checkpoint is committed with the rows it counts
+	return b.commit()
+}
+
+// Changes returns the nodes touched since they were last untouched, then the removed entities.
+func (b *BackendSQLite) Changes() ([]silo.Change, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	rows, err := b.reader().Query(`SELECT n.raw, c.version FROM changes c JOIN nodes n ON n.id = c.node`)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	defer rows.Close()
+
+	changes := []silo.Change{}
+
+	for rows.Next() {
+		var (
+			raw     []byte
+			version int64
+		)
+
+		if err := rows.Scan(&raw, &version); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		node, err := silo.DecodeDataNode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		changes = append(changes, silo.Change{Node: node, Version: version, Removed: ""})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return b.removals(changes)
+}
+
+// removals appends the removed entities to changes. The mutex must be held.
+func (b *BackendSQLite) removals(changes []silo.Change) ([]silo.Change, error) {
+	rows, err := b.reader().Query(`SELECT uuid, version FROM removals`)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		change := silo.Change{Node: silo.DataNode{Key: "", Data: nil}, Version: 0, Removed: ""}
+
+		if err := rows.Scan(&change.Removed, &change.Version); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return changes, nil
+}
+
+// Untouch forgets the changes, unless their node was touched again since.
+func (b *BackendSQLite) Untouch(changes ...silo.Change) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err := b.write(func(tx *sql.Tx) error {
+		for _, change := range changes {
+			if change.Removed != "" {
+				if _, err := tx.Exec(`DELETE FROM removals WHERE uuid = ? AND version = ?`,
+					change.Removed, change.Version); err != nil {
+					return fmt.Errorf("%w", err)
+				}
+
+				continue
+			}
+
+			raw, err := change.Node.Binary()
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			if _, err := tx.Exec(`DELETE FROM changes WHERE node = (SELECT id FROM nodes WHERE raw = ?)
+				AND version = ?`, raw, change.Version); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the end of a dump of changes is committed, like the pebble backend syncs it
+	return b.commit()
+}
+
+// EntityOf returns the id of the entity containing the node in the last dump of changes.
+func (b *BackendSQLite) EntityOf(node silo.DataNode) (string, bool, error) {
+	raw, err := node.Binary()
+	if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	var uuid string
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = b.reader().QueryRow(`SELECT uuid FROM entities WHERE node = (SELECT id FROM nodes WHERE raw = ?)`, raw).
+		Scan(&uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("%w", err)
+	}
+
+	return uuid, true, nil
+}
+
+// SetEntity records the id of the entity containing the nodes.
+func (b *BackendSQLite) SetEntity(uuid string, nodes ...silo.DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.write(func(tx *sql.Tx) error {
+		for _, node := range nodes {
+			raw, err := node.Binary()
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			if _, err := tx.Exec(`INSERT INTO entities (node, uuid) SELECT id, ? FROM nodes WHERE raw = ?
+				ON CONFLICT (node) DO UPDATE SET uuid = excluded.uuid`, uuid, raw); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (b *BackendSQLite) Snapshot() silo.Snapshot { //nolint:ireturn
+	// the snapshot reads the committed updates
+	err := b.flush()
+
+	var tx *sql.Tx
+	if err == nil {
+		tx, err = b.db.Begin()
+	}
+
+	if err == nil {
+		// a transaction reads a point-in-time view from its first read
+		var count int
+		if err = tx.QueryRow(`SELECT COUNT(*) FROM metadata`).Scan(&count); err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}
+
+	return &SnapshotSQLite{tx: tx, err: err, last: 0, pulled: make(map[int64]bool, DefaultPulledMapCap)}
+}
+
+func (b *BackendSQLite) Close() error {
+	if err := b.flush(); err != nil {
+		b.db.Close()
+
+		return err
+	}
+
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// SnapshotSQLite traverses the edges within a single SQLite transaction, so it reads a point-in-time view.
+type SnapshotSQLite struct {
+	tx     *sql.Tx
+	err    error // error of the transaction opening, returned by the first call
+	last   int64
+	pulled map[int64]bool
+}
+
+func (s *SnapshotSQLite) Next() (silo.DataNode, bool, error) {
+	if s.err != nil {
+		return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", s.err)
+	}
+
+	for {
+		var raw []byte
+
+		err := s.tx.QueryRow(`SELECT id, raw FROM nodes WHERE id > ?
+			AND EXISTS (SELECT 1 FROM edges WHERE source = nodes.id) ORDER BY id LIMIT 1`, s.last).Scan(&s.last, &raw)
+		if errors.Is(err, sql.ErrNoRows) {
+			return silo.DataNode{Key: "", Data: ""}, false, nil
+		} else if err != nil {
+			return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+		}
+
+		if s.pulled[s.last] {
+			continue
+		}
+
+		node, err := silo.DecodeDataNode(raw)
+		if err != nil {
+			return silo.DataNode{Key: "", Data: ""}, false, fmt.Errorf("%w", err)
+		}
+
+		return node, true, nil
+	}
+}
+
+func (s *SnapshotSQLite) PullAll(node silo.DataNode) ([]silo.DataNode, error) {
+	if s.err != nil {
+		return nil, fmt.Errorf("%w", s.err)
+	}
+
+	raw, err := node.Binary()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var id int64
+
+	err = s.tx.QueryRow(`SELECT id FROM nodes WHERE raw = ?`, raw).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) || s.pulled[id] {
+		return []silo.DataNode{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	s.pulled[id] = true
+
+	return querySet(s.tx, node)
+}
+
+func (s *SnapshotSQLite) Close() error {
+	if s.err != nil {
+		return nil
+	}
+
+	if err := s.tx.Rollback(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -18,11 +18,22 @@
 package infra_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cgi-fr/silo/internal/infra"
 	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cgi-fr/silo/pkg/silo/silotest"
+	"github.com/cockroachdb/pebble"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -82,3 +93,977 @@ func TestNominal(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, idnext, 3)
 }
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	node1 := silo.DataNode{Key: "ID1", Data: "1"}
+	node2 := silo.DataNode{Key: "ID2", Data: "1"}
+	node3 := silo.DataNode{Key: "ID3", Data: "1"}
+
+	require.NoError(t, backend.Store(node1, node2))
+	require.NoError(t, backend.Store(node1, node3))
+	require.NoError(t, backend.Remove(node1, node2))
+
+	nodes, err := backend.Get(node1)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{node3}, nodes)
+
+	require.NoError(t, backend.Remove(node1, node3))
+
+	snapshot := backend.Snapshot()
+
+	defer snapshot.Close()
+
+	_, ok, err := snapshot.Next()
+
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	source, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer source.Close()
+
+	target, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer target.Close()
+
+	require.NoError(t, source.Store(silo.DataNode{Key: "CUSTOMER", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}))
+	require.NoError(t, source.Store(silo.DataNode{Key: "EMAIL", Data: "a"}, silo.DataNode{Key: "CUSTOMER", Data: "1"}))
+	require.NoError(t, target.Store(silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "PHONE", Data: "0601"}))
+	require.NoError(t, target.Store(silo.DataNode{Key: "PHONE", Data: "0601"}, silo.DataNode{Key: "ID", Data: "1"}))
+
+	count, err := target.Merge(source, map[string]string{"CUSTOMER": "ID"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	nodes, err := target.Get(silo.DataNode{Key: "ID", Data: "1"})
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []silo.DataNode{{Key: "PHONE", Data: "0601"}, {Key: "EMAIL", Data: "a"}}, nodes)
+
+	nodes, err = target.Get(silo.DataNode{Key: "EMAIL", Data: "a"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{{Key: "ID", Data: "1"}}, nodes)
+}
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+
+	source, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer source.Close()
+
+	target, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer target.Close()
+
+	id := silo.DataNode{Key: "ID", Data: 1}
+	links := []silo.DataNode{
+		{Key: "NAME", Data: "Alice"},
+		{Key: "SCORE", Data: 12.5},
+		{Key: "RATIO", Data: float32(0.1)},
+		{Key: "ACCOUNT", Data: int64(1234567890123)},
+		{Key: "LEVEL", Data: uint8(3)},
+		{Key: "ACTIVE", Data: true},
+		{Key: "BRANCH_ACCOUNT", Data: silo.CompositeValue(`{"ACCOUNT":"12345","BRANCH":"001"}`)},
+		{Key: "CITY", Data: silo.AttributeValue{Sequence: 3, Value: "Paris"}},
+		silo.DataNode{Key: "ID", Data: 2}.Soft(),
+	}
+
+	require.NoError(t, source.StoreAll(id, links...))
+	require.NoError(t, source.Store(links[0], id))
+
+	checkpoint := silo.Checkpoint{Rows: 10, Sequence: 1, Time: time.Now().UTC(), Complete: false}
+	metadata := silo.Metadata{Scans: []silo.ScanRecord{}, Checkpoints: map[string]silo.Checkpoint{"clients": checkpoint}}
+
+	require.NoError(t, source.SetMetadata(metadata))
+
+	var buffer bytes.Buffer
+
+	count, err := infra.Export(source, &buffer)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = infra.Import(target, &buffer)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	nodes, err := target.Get(id)
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, links, nodes)
+
+	nodes, err = target.Get(links[0])
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{id}, nodes)
+
+	metadata, err = target.Metadata()
+
+	require.NoError(t, err)
+
+	restored, ok := metadata.Checkpoint("clients")
+
+	require.True(t, ok)
+	assert.Equal(t, checkpoint.Rows, restored.Rows)
+}
+
+func TestImportUnsupportedVersion(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	_, err = infra.Import(backend, bytes.NewBufferString(`{"format":"silo","version":99}`))
+
+	require.ErrorIs(t, err, infra.ErrUnsupportedExport)
+}
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	driver := silo.NewDriver(backend, nil, silo.WithSource("clients"))
+	rows := []silo.DataRow{{"ID": "1", "EMAIL": "a"}, {"ID": "2"}}
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+	require.Len(t, metadata.Scans, 1)
+	assert.Equal(t, "clients", metadata.Scans[0].Source)
+	assert.Equal(t, 2, metadata.Scans[0].Rows)
+
+	info, err := backend.Info()
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"ID": 2, "EMAIL": 1}, info.Keys)
+	assert.Equal(t, 3, info.Nodes)
+	assert.Equal(t, 1, info.Links)
+
+	// metadata is never read as a node
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	count := 0
+
+	for {
+		node, hasNext, err := snapshot.Next()
+
+		require.NoError(t, err)
+
+		if !hasNext {
+			break
+		}
+
+		_, err = snapshot.PullAll(node)
+
+		require.NoError(t, err)
+
+		count++
+	}
+
+	assert.Equal(t, 3, count)
+}
+
+func TestConcurrentStore(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	key := silo.DataNode{Key: "ID", Data: "1"}
+
+	var wait sync.WaitGroup
+
+	for i := 0; i < 100; i++ {
+		wait.Add(1)
+
+		go func(i int) {
+			defer wait.Done()
+
+			assert.NoError(t, backend.Store(key, silo.DataNode{Key: "EMAIL", Data: fmt.Sprintf("%d@domain.com", i)}))
+		}(i)
+	}
+
+	wait.Wait()
+
+	nodes, err := backend.Get(key)
+
+	require.NoError(t, err)
+	assert.Len(t, nodes, 100)
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackend(t.TempDir())
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	id, email := silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	// written after the snapshot, not visible by the dump
+	require.NoError(t, backend.Store(id, silo.DataNode{Key: "PHONE", Data: "0601"}))
+	require.NoError(t, backend.Store(silo.DataNode{Key: "ID", Data: "2"}, silo.DataNode{Key: "ID", Data: "2"}))
+
+	nodes, err := snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{email}, nodes)
+
+	node, hasNext, err := snapshot.Next()
+
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	assert.Equal(t, email, node)
+
+	_, err = snapshot.PullAll(email)
+
+	require.NoError(t, err)
+
+	_, hasNext, err = snapshot.Next()
+
+	require.NoError(t, err)
+	assert.False(t, hasNext)
+}
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+
+	backend, err := infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	id := silo.DataNode{Key: "ID", Data: "1"}
+	email := silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+
+	changes, err := backend.Changes()
+
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	// a node touched again after its change was read stays touched
+	require.NoError(t, backend.Store(id, id))
+	require.NoError(t, backend.Untouch(changes...))
+
+	changes, err = backend.Changes()
+
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, id, changes[0].Node)
+
+	require.NoError(t, backend.SetEntity("uuid", id, email))
+	require.NoError(t, backend.Close())
+
+	// changes and entities are persisted, and never read as nodes
+	backend, err = infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	uuid, found, err := backend.EntityOf(email)
+
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "uuid", uuid)
+
+	changes, err = backend.Changes()
+
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	info, err := backend.Info()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.Nodes)
+}
+
+func TestSQLite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo.db")
+
+	backend, err := infra.NewBackendSQLite(path)
+
+	require.NoError(t, err)
+
+	id, email := silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+
+	snapshot := backend.Snapshot()
+
+	// written after the snapshot, not visible by the dump
+	require.NoError(t, backend.Store(id, silo.DataNode{Key: "PHONE", Data: "0601"}))
+
+	nodes, err := snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{email}, nodes)
+
+	nodes, err = snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.Empty(t, nodes, "a node is pulled once")
+
+	node, hasNext, err := snapshot.Next()
+
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	assert.Equal(t, email, node)
+	require.NoError(t, snapshot.Close())
+	require.NoError(t, backend.Close())
+
+	// the graph can be read with SQL
+	database, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+
+	require.NoError(t, err)
+
+	defer database.Close()
+
+	var count int
+
+	require.NoError(t, database.QueryRow(
+		`SELECT COUNT(*) FROM edge_values WHERE source_key = 'ID' AND source_value = '"1"'`).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	readOnly, err := infra.NewBackendSQLiteReadOnly(path)
+
+	require.NoError(t, err)
+
+	defer readOnly.Close()
+
+	require.Error(t, readOnly.Store(email, email))
+
+	nodes, err = readOnly.Get(id)
+
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestSQLitePath(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+	path := filepath.Join(directory, "silo?mode=memory#1.db")
+
+	backend, err := infra.NewBackendSQLite(path)
+
+	require.NoError(t, err)
+
+	id := silo.DataNode{Key: "ID", Data: "1"}
+
+	require.NoError(t, backend.Store(id, id))
+	require.NoError(t, backend.Close())
+
+	// the file is named after the whole path, it is not read as parameters
+	entries, err := os.ReadDir(directory)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, "silo?mode=memory#1.db", entries[0].Name())
+
+	backend, err = infra.NewBackendSQLite(path)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	nodes, err := backend.Get(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{id}, nodes)
+}
+
+func TestSQLiteBatch(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo.db")
+
+	backend, err := infra.NewBackendSQLite(path)
+
+	require.NoError(t, err)
+
+	const count = 2500 // more than two batches
+
+	for i := 0; i < count; i++ {
+		id := silo.DataNode{Key: "ID", Data: strconv.Itoa(i)}
+		require.NoError(t, backend.Store(id, silo.DataNode{Key: "EMAIL", Data: fmt.Sprintf("%d@example.org", i)}))
+	}
+
+	// pending updates are read before they are committed
+	nodes, err := backend.Get(silo.DataNode{Key: "ID", Data: strconv.Itoa(count - 1)})
+
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+
+	// a failed update is undone alone, the updates grouped before it are kept
+	id := silo.DataNode{Key: "ID", Data: "0"}
+	invalid := silo.DataNode{Key: "INVALID", Data: make(chan int)}
+
+	require.Error(t, backend.StoreAll(id, silo.DataNode{Key: "PHONE", Data: "0601"}, invalid))
+
+	nodes, err = backend.Get(id)
+
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	require.NoError(t, backend.Close())
+
+	database, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+
+	require.NoError(t, err)
+
+	defer database.Close()
+
+	var rows int
+
+	require.NoError(t, database.QueryRow(`SELECT COUNT(*) FROM edges`).Scan(&rows))
+	assert.Equal(t, count, rows, "pending updates are committed on close")
+
+	require.NoError(t, database.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'edges' AND sql LIKE '%target%'`).
+		Scan(&rows))
+	assert.Equal(t, 1, rows, "edges are indexed by target")
+}
+
+func TestSpill(t *testing.T) {
+	temp := t.TempDir()
+	t.Setenv("TMPDIR", temp)
+
+	backend, err := infra.NewBackendSpill(filepath.Join(t.TempDir(), "silo"), 1)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	for i := 0; i < 100; i += 2 {
+		id, email := silo.DataNode{Key: "ID", Data: i}, silo.DataNode{Key: "EMAIL", Data: fmt.Sprint(i)}
+
+		require.NoError(t, backend.Store(id, email))
+		require.NoError(t, backend.Store(email, id))
+	}
+
+	// with a budget of one byte, every visited node is spilled to disk
+	snapshot := backend.Snapshot()
+	entities := 0
+
+	for {
+		node, hasNext, err := snapshot.Next()
+
+		require.NoError(t, err)
+
+		if !hasNext {
+			break
+		}
+
+		nodes, err := snapshot.PullAll(node)
+
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+
+		for _, next := range nodes {
+			_, err := snapshot.PullAll(next)
+
+			require.NoError(t, err)
+		}
+
+		entities++
+	}
+
+	assert.Equal(t, 50, entities)
+
+	spilled, err := filepath.Glob(filepath.Join(temp, "silo-dump-*"))
+
+	require.NoError(t, err)
+	assert.Len(t, spilled, 1)
+
+	require.NoError(t, snapshot.Close())
+
+	spilled, err = filepath.Glob(filepath.Join(temp, "silo-dump-*"))
+
+	require.NoError(t, err)
+	assert.Empty(t, spilled, "the spill directory is removed when the snapshot is closed")
+}
+
+func TestSpillBuffer(t *testing.T) {
+	t.Parallel()
+
+	backend, err := infra.NewBackendSpill(filepath.Join(t.TempDir(), "silo"), 1)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	snapshot := backend.Snapshot()
+
+	defer snapshot.Close()
+
+	buffered, ok := snapshot.(silo.BufferedSnapshot)
+
+	require.True(t, ok)
+
+	// with a budget of one byte, the nodes of the entity and the frontier are spilled to disk
+	buffer := buffered.Buffer()
+
+	for entity := 0; entity < 2; entity++ {
+		require.NoError(t, buffer.Reset())
+
+		for i := 0; i < 100; i++ {
+			added, err := buffer.Add(silo.DataNode{Key: "ID", Data: i})
+
+			require.NoError(t, err)
+			require.True(t, added)
+			require.NoError(t, buffer.Push(silo.DataNode{Key: "ID", Data: i}))
+		}
+
+		added, err := buffer.Add(silo.DataNode{Key: "ID", Data: 0})
+
+		require.NoError(t, err)
+		assert.False(t, added, "a node spilled to disk is already added")
+
+		for i := 99; i >= 0; i-- {
+			node, ok, err := buffer.Pop()
+
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, silo.DataNode{Key: "ID", Data: i}, node)
+		}
+
+		_, ok, err := buffer.Pop()
+
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		nodes := []silo.DataNode{}
+
+		require.NoError(t, buffer.ForEach(func(node silo.DataNode) error {
+			nodes = append(nodes, node)
+
+			return nil
+		}))
+
+		assert.Len(t, nodes, 100, "the nodes of the previous entity are reset")
+		assert.Equal(t, silo.DataNode{Key: "ID", Data: 0}, nodes[0])
+		assert.Equal(t, silo.DataNode{Key: "ID", Data: 99}, nodes[99])
+	}
+}
+
+func TestChooseStrategy(t *testing.T) {
+	t.Parallel()
+
+	small := infra.SizeEstimate{Keys: 1000, KeyBytes: 32_000, ValueBytes: 64_000}
+
+	strategy, _ := infra.ChooseStrategy(small, 1<<30)
+	assert.Equal(t, "full", strategy)
+
+	strategy, _ = infra.ChooseStrategy(small, small.Full())
+	assert.Equal(t, "iterate-once", strategy, "the full strategy needs twice its estimate to be chosen")
+
+	strategy, budget := infra.ChooseStrategy(small, small.Visited())
+	assert.Equal(t, "spill", strategy)
+	assert.Equal(t, 1<<20, budget, "the budget has a minimum")
+
+	large := infra.SizeEstimate{Keys: 1 << 30, KeyBytes: 32 << 30, ValueBytes: 64 << 30}
+
+	strategy, budget = infra.ChooseStrategy(large, 8<<30)
+	assert.Equal(t, "spill", strategy)
+	assert.Equal(t, 2<<30, budget)
+}
+
+func TestEstimate(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, backend.Store(silo.DataNode{Key: "ID", Data: i}, silo.DataNode{Key: "EMAIL", Data: i}))
+	}
+
+	// tables are written when the database is closed
+	require.NoError(t, backend.Close())
+
+	backend, err = infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	estimate, err := backend.Estimate()
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, estimate.Keys, uint64(100))
+	assert.Greater(t, estimate.Full(), estimate.Visited())
+}
+
+func TestSharded(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackendSharded(path, 4)
+
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		id, email := silo.DataNode{Key: "ID", Data: i}, silo.DataNode{Key: "EMAIL", Data: fmt.Sprint(i)}
+
+		require.NoError(t, backend.Store(id, email))
+		require.NoError(t, backend.Store(email, id))
+	}
+
+	require.NoError(t, backend.SetMetadata(silo.Metadata{Scans: []silo.ScanRecord{{Rows: 100}}})) //nolint:exhaustruct
+	require.NoError(t, backend.Close())
+
+	// nodes are spread over all shards, each shard is a pebble database
+	for i := 0; i < 4; i++ {
+		shard, err := infra.NewBackendReadOnly(filepath.Join(path, fmt.Sprintf("shard-%03d", i)))
+
+		require.NoError(t, err)
+
+		count := 0
+
+		require.NoError(t, shard.ForEach(func(_ silo.DataNode, _ []silo.DataNode) error {
+			count++
+
+			return nil
+		}))
+		require.NoError(t, shard.Close())
+		assert.Positive(t, count)
+	}
+
+	// the number of shards is read from the silo
+	uri, err := infra.ParseURI("sharded://"+path, infra.DefaultScheme)
+
+	require.NoError(t, err)
+
+	storage, err := infra.Open(uri, true)
+
+	require.NoError(t, err)
+
+	metadata, err := storage.Metadata()
+
+	require.NoError(t, err)
+	assert.Equal(t, 100, metadata.Scans[0].Rows)
+
+	groups := silotest.Entities(t, storage)
+
+	require.NoError(t, storage.Close())
+	assert.Len(t, groups, 100, "entities spread over several shards are resolved")
+
+	uri.Params.Set("shards", "2")
+
+	_, err = infra.Open(uri, false)
+
+	require.ErrorIs(t, err, infra.ErrInvalidBackendURI)
+
+	// a pebble silo is not a sharded silo
+	pebblePath := filepath.Join(t.TempDir(), "pebble")
+	pebble, err := infra.NewBackend(pebblePath)
+
+	require.NoError(t, err)
+	require.NoError(t, pebble.Close())
+
+	_, err = infra.NewBackendSharded(pebblePath, 2)
+
+	require.ErrorIs(t, err, infra.ErrPathIsNotValid)
+}
+
+func TestShardedFailFast(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackendSharded(path, 2)
+
+	require.NoError(t, err)
+	require.NoError(t, backend.Close())
+
+	uri, err := infra.ParseURI("sharded://"+path, infra.DefaultScheme)
+
+	require.NoError(t, err)
+
+	// writes to read-only shards fail in the goroutine of the shard
+	storage, err := infra.Open(uri, true)
+
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	id, email := silo.DataNode{Key: "ID", Data: 1}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, storage.Store(id, email), "the write is queued")
+
+	_, err = storage.Get(id)
+
+	require.Error(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Error(t, storage.Store(silo.DataNode{Key: "ID", Data: i}, email), "every following write fails")
+	}
+}
+
+func TestCheckUndecodable(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	id, email := silo.DataNode{Key: "ID", Data: 1}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+	require.NoError(t, backend.Close())
+
+	// corrupt the set of a node, and add keys that are not nodes
+	database, err := pebble.Open(path, &pebble.Options{Logger: infra.BackendLogger{}}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+
+	rawEmail, err := email.Binary()
+
+	require.NoError(t, err)
+	require.NoError(t, database.Set(rawEmail, []byte("garbage"), pebble.Sync))
+	require.NoError(t, database.Set([]byte("garbage"), []byte("garbage"), pebble.Sync))
+	require.NoError(t, database.Set([]byte{}, []byte("garbage"), pebble.Sync))
+	require.NoError(t, database.Close())
+
+	backend, err = infra.NewBackendReadOnly(path)
+
+	require.NoError(t, err)
+
+	defer backend.Close()
+
+	report, err := infra.Check(backend, false)
+
+	require.NoError(t, err)
+	require.Len(t, report.Issues, 3)
+
+	for _, issue := range report.Issues {
+		assert.Equal(t, infra.IssueUndecodable, issue.Kind)
+	}
+	assert.Equal(t, 1, report.Nodes, "decodable nodes are still checked")
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pebble", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pebble", "a"), make([]byte, 10), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pebble", "sub", "b"), make([]byte, 5), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "silo.db"), make([]byte, 100), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "silo.db-wal"), make([]byte, 20), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "silo.db.bak"), make([]byte, 1000), 0o600))
+
+	size, err := infra.DiskUsage(filepath.Join(dir, "pebble"))
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), size)
+
+	size, err = infra.DiskUsage(filepath.Join(dir, "silo.db"))
+
+	require.NoError(t, err)
+	assert.Equal(t, uint64(120), size, "journal files are counted, other files are not")
+
+	_, err = infra.DiskUsage(filepath.Join(dir, "missing"))
+
+	assert.Error(t, err)
+}
+
+// secret is the value erased by the delete tests, it must not be found in any key or value afterwards.
+const secret = "secretperson@example.org"
+
+// deleteSecret scans the secret with a name attribute, dumps the changes, then deletes the secret and dumps the
+// changes again.
+func deleteSecret(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	rows := []silo.DataRow{
+		{"ID": "1", "EMAIL": secret, "NAME": "secret person"},
+		{"ID": "2", "EMAIL": "other@example.org"},
+	}
+
+	driver := silo.NewDriver(backend, infra.NewDumpJSONLineTo(io.Discard, false), silo.WithAttributes([]string{"NAME"}))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+	require.NoError(t, driver.DumpChanged(context.Background()))
+	require.NoError(t, driver.Delete(silo.DataNode{Key: "EMAIL", Data: secret}))
+	require.NoError(t, driver.DumpChanged(context.Background()))
+
+	nodes, err := backend.Get(silo.DataNode{Key: "ID", Data: "1"})
+
+	require.NoError(t, err)
+	assert.NotContains(t, nodes, silo.DataNode{Key: "EMAIL", Data: secret})
+}
+
+func TestDeleteErasesPebble(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo")
+
+	backend, err := infra.NewBackend(path)
+
+	require.NoError(t, err)
+
+	deleteSecret(t, backend)
+
+	require.NoError(t, backend.Close())
+
+	// every key is read, including the keys reserved for metadata and change tracking
+	database, err := pebble.Open(path, &pebble.Options{Logger: infra.BackendLogger{}}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+
+	defer database.Close()
+
+	iter, err := database.NewIter(&pebble.IterOptions{}) //nolint:exhaustruct
+
+	require.NoError(t, err)
+
+	defer iter.Close()
+
+	keys := 0
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		keys++
+
+		assert.NotContains(t, string(iter.Key()), secret)
+		assert.NotContains(t, string(iter.Value()), secret)
+	}
+
+	assert.Positive(t, keys)
+}
+
+func TestDeleteErasesSQLite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo.db")
+
+	backend, err := infra.NewBackendSQLite(path)
+
+	require.NoError(t, err)
+
+	deleteSecret(t, backend)
+
+	require.NoError(t, backend.Close())
+
+	database, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+
+	require.NoError(t, err)
+
+	defer database.Close()
+
+	var rows int
+
+	require.NoError(t, database.QueryRow(`SELECT COUNT(*) FROM nodes WHERE CAST(raw AS TEXT) LIKE ? OR value LIKE ?`,
+		"%"+secret+"%", "%"+secret+"%").Scan(&rows))
+	assert.Zero(t, rows)
+
+	// rows of changes and entities must reference existing nodes
+	require.NoError(t, database.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM changes WHERE node NOT IN (SELECT id FROM nodes)) +
+		(SELECT COUNT(*) FROM entities WHERE node NOT IN (SELECT id FROM nodes))`).Scan(&rows))
+	assert.Zero(t, rows)
+
+	content, err := os.ReadFile(path)
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), secret, "deleted rows are overwritten")
+}
+
+func TestDeleteErasesBolt(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "silo.bolt")
+
+	backend, err := infra.NewBackendBolt(path)
+
+	require.NoError(t, err)
+
+	deleteSecret(t, backend)
+
+	// freed pages keep their content until the file is rewritten by the compaction
+	_, err = infra.Compact(backend)
+
+	require.NoError(t, err)
+	require.NoError(t, backend.Close())
+
+	content, err := os.ReadFile(path)
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), secret, "deleted sets are not copied")
+
+	readOnly, err := infra.NewBackendBoltReadOnly(path)
+
+	require.NoError(t, err)
+
+	defer readOnly.Close()
+
+	require.Error(t, readOnly.Store(silo.DataNode{Key: "ID", Data: 1}, silo.DataNode{Key: "ID", Data: 1}))
+
+	report, err := infra.Check(readOnly, false)
+
+	require.NoError(t, err)
+	assert.Empty(t, report.Issues)
+	assert.Positive(t, report.Nodes)
+}
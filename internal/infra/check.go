@@ -0,0 +1,488 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cockroachdb/pebble"
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+)
+
+// Kinds of issues found by Check.
+const (
+	IssueUndecodable = "undecodable"
+	IssueAsymmetric  = "asymmetric"
+	IssueMisplaced   = "misplaced"
+	IssueMetadata    = "metadata"
+	IssueIncomplete  = "incomplete"
+)
+
+// Issue is an inconsistency found in a silo, Node renders the node concerned if any. An issue marked Info reports
+// an expected state, such as a scan to resume, and does not count as an inconsistency.
+type Issue struct {
+	Kind     string `json:"kind"`
+	Node     string `json:"node,omitempty"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired,omitempty"`
+	Info     bool   `json:"info,omitempty"`
+}
+
+// CheckReport lists the issues found in a silo, Links counts each direction of the links between nodes.
+type CheckReport struct {
+	Nodes  int     `json:"nodes"`
+	Links  int     `json:"links"`
+	Issues []Issue `json:"issues"`
+}
+
+// Inconsistencies returns the number of issues that are not information.
+func (r CheckReport) Inconsistencies() int {
+	count := 0
+
+	for _, issue := range r.Issues {
+		if !issue.Info {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Unrepaired returns the number of inconsistencies left in the silo.
+func (r CheckReport) Unrepaired() int {
+	count := 0
+
+	for _, issue := range r.Issues {
+		if !issue.Repaired && !issue.Info {
+			count++
+		}
+	}
+
+	return count
+}
+
+// verifier is implemented by backends able to detect entries that cannot be decoded, instead of failing on them.
+type verifier interface {
+	// verify calls fn with each decodable node and its set, and returns the entries that cannot be decoded.
+	verify(fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error)
+}
+
+func verify(backend Storage, fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error) {
+	if verifier, ok := backend.(verifier); ok {
+		return verifier.verify(fn)
+	}
+
+	if err := backend.ForEach(fn); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return []Issue{}, nil
+}
+
+// Check verifies that every entry of the silo can be decoded, that each link is stored in both directions (soft links
+// included, attributes are stored in a single direction) and that the metadata are consistent. With repair, the
+// missing directions of asymmetric links are stored, as a scan stopped between the two writes of a link would have.
+func Check(backend Storage, repair bool) (CheckReport, error) {
+	report := CheckReport{Nodes: 0, Links: 0, Issues: []Issue{}}
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		report.Issues = append(report.Issues, Issue{
+			Kind: IssueMetadata, Node: "", Detail: fmt.Sprintf("metadata cannot be decoded : %v", err), Repaired: false,
+			Info: false,
+		})
+	} else {
+		report.Issues = append(report.Issues, checkMetadata(metadata)...)
+	}
+
+	missing := []silo.DataLink{}
+
+	issues, err := verify(backend, func(key silo.DataNode, values []silo.DataNode) error {
+		report.Nodes++
+
+		for _, value := range values {
+			if value.IsAttribute() || value == key {
+				continue
+			}
+
+			report.Links++
+
+			// the reverse of a soft link to value is a soft link from value
+			target, expected := value, key
+			if value.IsSoft() {
+				target, expected = value.Hard(), key.Soft()
+			}
+
+			found, err := contains(backend, target, expected)
+			if err != nil {
+				// the set of target cannot be read, it is reported as undecodable
+				log.Debug().Err(err).Str("node", target.String()).Msg("symmetry not checked")
+
+				continue
+			}
+
+			if !found {
+				missing = append(missing, silo.DataLink{E1: target, E2: expected})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Issues = append(report.Issues, issues...)
+
+	for _, link := range missing {
+		issue := Issue{
+			Kind: IssueAsymmetric, Node: link.E1.String(), Detail: "link to " + link.E2.String() + " is missing",
+			Repaired: false,
+			Info:     false,
+		}
+
+		if repair {
+			if err := backend.Store(link.E1, link.E2); err != nil {
+				return report, fmt.Errorf("%w", err)
+			}
+
+			issue.Repaired = true
+		}
+
+		report.Issues = append(report.Issues, issue)
+	}
+
+	return report, nil
+}
+
+func contains(backend Storage, key silo.DataNode, value silo.DataNode) (bool, error) {
+	values, err := backend.Get(key)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	for _, candidate := range values {
+		if candidate == value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkMetadata returns the inconsistencies of the metadata : counters must not be negative. A checkpoint without
+// scan is left by a scan that did not end, it is reported as information since --resume is designed for it.
+func checkMetadata(metadata silo.Metadata) []Issue {
+	issues := []Issue{}
+	sources := map[string]bool{}
+
+	for index, scan := range metadata.Scans {
+		sources[scan.Source] = true
+
+		if scan.Rows < 0 || scan.Links < 0 || scan.Skipped < 0 {
+			issues = append(issues, Issue{
+				Kind: IssueMetadata, Node: "", Detail: fmt.Sprintf("scan %d has negative counters", index+1),
+				Repaired: false,
+				Info:     false,
+			})
+		}
+	}
+
+	for source, checkpoint := range metadata.Checkpoints {
+		if checkpoint.Rows < 0 {
+			issues = append(issues, Issue{
+				Kind: IssueMetadata, Node: "", Detail: fmt.Sprintf("checkpoint of source %q is negative", source),
+				Repaired: false,
+				Info:     false,
+			})
+		}
+
+		if !sources[source] {
+			issues = append(issues, Issue{
+				Kind:     IssueIncomplete,
+				Node:     "",
+				Detail:   fmt.Sprintf("scan of source %q did not end, resume the scan with --resume", source),
+				Repaired: false,
+				Info:     true,
+			})
+		}
+	}
+
+	return issues
+}
+
+func undecodable(node string, format string, args ...any) Issue {
+	return Issue{Kind: IssueUndecodable, Node: node, Detail: fmt.Sprintf(format, args...), Repaired: false, Info: false}
+}
+
+// verify reads every key of the database, including the keys reserved for change tracking.
+func (b Backend) verify(fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error) {
+	issues := []Issue{}
+
+	iter, err := b.db.NewIter(&pebble.IterOptions{}) //nolint:exhaustruct
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		rawKey := iter.Key()
+
+		switch {
+		case bytes.Equal(rawKey, metadataKey):
+			// decoded by Check
+		case bytes.HasPrefix(rawKey, touchedPrefix):
+			if _, err := decodeKey(rawKey[len(touchedPrefix):]); err != nil {
+				issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "change cannot be decoded : %v", err))
+			} else if len(iter.Value()) != binary.Size(uint64(0)) {
+				issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "version of change is invalid"))
+			}
+		case bytes.HasPrefix(rawKey, removedPrefix):
+			if len(iter.Value()) != binary.Size(uint64(0)) {
+				issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "version of removal is invalid"))
+			}
+		case bytes.HasPrefix(rawKey, entityPrefix):
+			if _, err := decodeKey(rawKey[len(entityPrefix):]); err != nil {
+				issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "entity cannot be decoded : %v", err))
+			}
+		case len(rawKey) == 0:
+			issues = append(issues, undecodable("", "empty key"))
+		case rawKey[0] == 0x00:
+			issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "unknown reserved key"))
+		default:
+			key, err := decodeKey(rawKey)
+			if err != nil {
+				issues = append(issues, undecodable(fmt.Sprintf("%x", rawKey), "node cannot be decoded : %v", err))
+
+				continue
+			}
+
+			values, err := decode(iter.Value())
+			if err != nil {
+				issues = append(issues, undecodable(key.String(), "links cannot be decoded : %v", err))
+
+				continue
+			}
+
+			if err := fn(key, values); err != nil {
+				return issues, err
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// verify reads every node, edges to or from a node that cannot be decoded are skipped.
+func (b *BackendSQLite) verify(fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error) {
+	// fn can repair the silo, so the rows are read out of the write transaction
+	if err := b.flush(); err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	invalid := map[int64]bool{}
+
+	rows, err := b.db.Query(`SELECT id, raw FROM nodes`)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	for rows.Next() {
+		var (
+			id  int64
+			raw []byte
+		)
+
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if _, err := silo.DecodeDataNode(raw); err != nil {
+			issues = append(issues, undecodable(fmt.Sprintf("node id %d", id), "node cannot be decoded : %v", err))
+			invalid[id] = true
+		}
+	}
+
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	rows, err = b.db.Query(`SELECT s.id, s.raw, t.id, t.raw FROM edges e JOIN nodes s ON s.id = e.source
+		JOIN nodes t ON t.id = e.target ORDER BY e.source`)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	defer rows.Close()
+
+	var (
+		current int64
+		key     silo.DataNode
+		values  []silo.DataNode
+	)
+
+	for rows.Next() {
+		var (
+			source, target       int64
+			rawSource, rawTarget []byte
+		)
+
+		if err := rows.Scan(&source, &rawSource, &target, &rawTarget); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if invalid[source] || invalid[target] {
+			continue
+		}
+
+		if source != current {
+			if values != nil {
+				if err := fn(key, values); err != nil {
+					return issues, err
+				}
+			}
+
+			// both nodes were decoded above
+			key, _ = silo.DecodeDataNode(rawSource)
+			current, values = source, []silo.DataNode{}
+		}
+
+		value, _ := silo.DecodeDataNode(rawTarget)
+		values = append(values, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if values != nil {
+		return issues, fn(key, values)
+	}
+
+	return issues, nil
+}
+
+// verify reads every bucket, including the buckets of the change tracking.
+func (b *BackendBolt) verify(fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error) {
+	// fn can repair the silo, so the sets are read out of the write transaction
+	if err := b.flush(); err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+			return bucket.ForEach(func(rawKey, value []byte) error {
+				issue, ok := verifyBoltEntry(name, rawKey, value)
+				if !ok {
+					issues = append(issues, issue)
+
+					return nil
+				}
+
+				if !bytes.Equal(name, boltNodes) {
+					return nil
+				}
+
+				// decoded by verifyBoltEntry
+				key, _ := decodeKey(rawKey)
+				values, _ := decode(value)
+
+				return fn(key, values)
+			})
+		})
+	})
+	if err != nil {
+		return issues, fmt.Errorf("%w", err)
+	}
+
+	return issues, nil
+}
+
+// verifyBoltEntry returns the issue of an entry of a bolt silo, or true if it can be decoded.
+func verifyBoltEntry(bucket []byte, rawKey []byte, value []byte) (Issue, bool) {
+	switch {
+	case bytes.Equal(bucket, boltMetadata):
+		// decoded by Check
+	case bytes.Equal(bucket, boltTouched):
+		if _, err := decodeKey(rawKey); err != nil {
+			return undecodable(fmt.Sprintf("%x", rawKey), "change cannot be decoded : %v", err), false
+		} else if len(value) != binary.Size(uint64(0)) {
+			return undecodable(fmt.Sprintf("%x", rawKey), "version of change is invalid"), false
+		}
+	case bytes.Equal(bucket, boltRemoved):
+		if len(value) != binary.Size(uint64(0)) {
+			return undecodable(fmt.Sprintf("%x", rawKey), "version of removal is invalid"), false
+		}
+	case bytes.Equal(bucket, boltEntities):
+		if _, err := decodeKey(rawKey); err != nil {
+			return undecodable(fmt.Sprintf("%x", rawKey), "entity cannot be decoded : %v", err), false
+		}
+	case bytes.Equal(bucket, boltNodes):
+		if _, err := decodeKey(rawKey); err != nil {
+			return undecodable(fmt.Sprintf("%x", rawKey), "node cannot be decoded : %v", err), false
+		}
+
+		if _, err := decode(value); err != nil {
+			return undecodable(fmt.Sprintf("%x", rawKey), "links cannot be decoded : %v", err), false
+		}
+	default:
+		return undecodable(fmt.Sprintf("%x", rawKey), "unknown bucket %q", bucket), false
+	}
+
+	return Issue{}, true //nolint:exhaustruct
+}
+
+// verify verifies each shard, and that each node is stored in the shard selected by its hash.
+func (b *BackendSharded) verify(fn func(key silo.DataNode, values []silo.DataNode) error) ([]Issue, error) {
+	if err := b.flush(b.shards...); err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+
+	for index, shard := range b.shards {
+		shardIssues, err := verify(shard.Storage, func(key silo.DataNode, values []silo.DataNode) error {
+			if expected, err := shardIndex(key, len(b.shards)); err != nil || expected != index {
+				issues = append(issues, Issue{
+					Kind: IssueMisplaced, Node: key.String(), Detail: fmt.Sprintf("stored in %s", shardName(index)),
+					Repaired: false,
+					Info:     false,
+				})
+			}
+
+			return fn(key, values)
+		})
+		if err != nil {
+			return issues, err
+		}
+
+		issues = append(issues, shardIssues...)
+	}
+
+	return issues, nil
+}
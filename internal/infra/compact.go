@@ -0,0 +1,490 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cockroachdb/pebble"
+	"go.etcd.io/bbolt"
+)
+
+var ErrCompactNotSupported = errors.New("backend does not support compaction")
+
+const (
+	// compactBatchSize is the number of rewritten sets committed at once.
+	compactBatchSize = 10000
+
+	// compactTxSize is the size of the data copied by each transaction of the compaction of a bolt silo.
+	compactTxSize = 64 << 20
+
+	// obsoleteTimeout bounds the wait for the deletion of obsolete tables, polled every obsoletePollInterval.
+	obsoleteTimeout      = 10 * time.Second
+	obsoletePollInterval = 10 * time.Millisecond
+)
+
+// CompactReport summarizes a compaction, sizes are in bytes.
+type CompactReport struct {
+	Nodes          int    `json:"nodes"`
+	SelfReferences int    `json:"selfReferences"`
+	SizeBefore     uint64 `json:"sizeBefore"`
+	SizeAfter      uint64 `json:"sizeAfter"`
+}
+
+// Reclaimed returns the space freed by the compaction.
+func (r CompactReport) Reclaimed() uint64 {
+	if r.SizeAfter > r.SizeBefore {
+		return 0
+	}
+
+	return r.SizeBefore - r.SizeAfter
+}
+
+func (r CompactReport) add(other CompactReport) CompactReport {
+	return CompactReport{
+		Nodes:          r.Nodes + other.Nodes,
+		SelfReferences: r.SelfReferences + other.SelfReferences,
+		SizeBefore:     r.SizeBefore + other.SizeBefore,
+		SizeAfter:      r.SizeAfter + other.SizeAfter,
+	}
+}
+
+// Compact reclaims the space of obsolete data in the silo, if its backend supports it.
+func Compact(backend Storage) (CompactReport, error) {
+	compacter, ok := backend.(interface {
+		Compact() (CompactReport, error)
+	})
+	if !ok {
+		return CompactReport{}, ErrCompactNotSupported //nolint:exhaustruct
+	}
+
+	report, err := compacter.Compact()
+	if err != nil || report.SelfReferences == 0 {
+		return report, err //nolint:wrapcheck
+	}
+
+	// retractions must keep the values that rows contributed on their own, their self references are dropped
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	metadata.Compacted = true
+
+	if err := backend.SetMetadata(metadata); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	return report, nil
+}
+
+// DiskUsage returns the space used on disk by the silo stored at location : the size of the files under a directory,
+// or the size of a database file and of its SQLite journal files.
+func DiskUsage(location string) (uint64, error) {
+	var size uint64
+
+	if _, err := os.Stat(location); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	for _, path := range []string{location, location + "-wal", location + "-shm", location + "-journal"} {
+		err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				return nil
+			case err != nil:
+				return err
+			case entry.IsDir():
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			size += uint64(info.Size())
+
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	return size, nil
+}
+
+// compactSet drops the self reference of key from its set when the node has a link to another node : a scan stores
+// a self reference only for rows with a single node, so the node exists in the silo, and it becomes redundant once
+// the node is linked. Attributes do not link nodes, a self reference next to attributes only is kept.
+func compactSet(key silo.DataNode, values []silo.DataNode) ([]silo.DataNode, bool) {
+	self, linked := false, false
+
+	for _, value := range values {
+		switch {
+		case value == key:
+			self = true
+		case !value.IsAttribute():
+			linked = true
+		}
+	}
+
+	if !self || !linked {
+		return values, false
+	}
+
+	compacted := make([]silo.DataNode, 0, len(values)-1)
+
+	for _, value := range values {
+		if value != key {
+			compacted = append(compacted, value)
+		}
+	}
+
+	return compacted, true
+}
+
+// Compact drops redundant self references, then compacts the whole database so the obsolete versions of the sets
+// left by successive updates are removed. Changes are not tracked, as the entities are not altered.
+func (b Backend) Compact() (CompactReport, error) {
+	report := CompactReport{Nodes: 0, SelfReferences: 0, SizeBefore: b.db.Metrics().DiskSpaceUsage(), SizeAfter: 0}
+
+	if err := b.dropSelfReferences(&report); err != nil {
+		return report, err
+	}
+
+	if err := b.db.Flush(); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	iter, err := b.db.NewIter(&pebble.IterOptions{}) //nolint:exhaustruct
+	if err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	var first, last []byte
+
+	if iter.First() {
+		first = append([]byte{}, iter.Key()...)
+	}
+
+	if iter.Last() {
+		// the end of the compacted range is exclusive
+		last = append(append([]byte{}, iter.Key()...), 0x00)
+	}
+
+	if err := iter.Close(); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	if first != nil {
+		if err := b.db.Compact(first, last, true); err != nil {
+			return report, fmt.Errorf("%w", err)
+		}
+	}
+
+	report.SizeAfter = b.sizeAfterCleanup()
+
+	return report, nil
+}
+
+// sizeAfterCleanup returns the disk space used by the database once the tables made obsolete by a compaction are
+// deleted, they are deleted in background. After obsoleteTimeout, the space still used by them is counted.
+func (b Backend) sizeAfterCleanup() uint64 {
+	deadline := time.Now().Add(obsoleteTimeout)
+
+	for {
+		metrics := b.db.Metrics()
+
+		if metrics.Table.ObsoleteCount == 0 && metrics.Table.ZombieCount == 0 || time.Now().After(deadline) {
+			return metrics.DiskSpaceUsage()
+		}
+
+		time.Sleep(obsoletePollInterval)
+	}
+}
+
+func (b Backend) dropSelfReferences(report *CompactReport) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	iter, err := b.db.NewIter(nodesIterOptions())
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	defer iter.Close()
+
+	batch := b.db.NewBatch()
+	defer func() { batch.Close() }()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		report.Nodes++
+
+		key, err := decodeKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		values, err := decode(iter.Value())
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		compacted, changed := compactSet(key, values)
+		if !changed {
+			continue
+		}
+
+		report.SelfReferences++
+
+		rawValues, err := encode(compacted)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := batch.Set(append([]byte{}, iter.Key()...), rawValues, nil); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if batch.Count() >= compactBatchSize {
+			if err := batch.Commit(pebble.Sync); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			if err := batch.Close(); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			batch = b.db.NewBatch()
+		}
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Compact drops redundant self references, then rebuilds the database file with VACUUM.
+func (b *BackendSQLite) Compact() (CompactReport, error) {
+	report := CompactReport{Nodes: 0, SelfReferences: 0, SizeBefore: 0, SizeAfter: 0}
+
+	var err error
+
+	if report.SizeBefore, err = b.size(); err != nil {
+		return report, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.commit(); err != nil {
+		return report, err
+	}
+
+	if err := b.db.QueryRow(`SELECT COUNT(DISTINCT source) FROM edges`).Scan(&report.Nodes); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	result, err := b.db.Exec(`
+		DELETE FROM edges WHERE source = target AND EXISTS (
+			SELECT 1 FROM edges other JOIN nodes target ON target.id = other.target
+			WHERE other.source = edges.source AND other.target != other.source AND target.kind != 'attribute'
+		)`)
+	if err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	dropped, err := result.RowsAffected()
+	if err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	report.SelfReferences = int(dropped)
+
+	if _, err := b.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	if _, err := b.db.Exec(`VACUUM`); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	if report.SizeAfter, err = b.size(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// size returns the size of the database file, in bytes.
+func (b *BackendSQLite) size() (uint64, error) {
+	var size uint64
+
+	err := b.db.QueryRow(
+		`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return size, nil
+}
+
+// Compact compacts each shard in turn.
+func (b *BackendSharded) Compact() (CompactReport, error) {
+	report := CompactReport{Nodes: 0, SelfReferences: 0, SizeBefore: 0, SizeAfter: 0}
+
+	if err := b.flush(b.shards...); err != nil {
+		return report, err
+	}
+
+	for _, shard := range b.shards {
+		shardReport, err := Compact(shard.Storage)
+		if err != nil {
+			return report, err
+		}
+
+		report = report.add(shardReport)
+	}
+
+	return report, nil
+}
+
+// Compact drops redundant self references, then copies the silo into a new file that replaces it : bolt never shrinks
+// its file, and the pages freed by deletions keep their content until they are reused.
+func (b *BackendBolt) Compact() (CompactReport, error) {
+	report := CompactReport{Nodes: 0, SelfReferences: 0, SizeBefore: 0, SizeAfter: 0}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err := b.commit(); err != nil {
+		return report, err
+	}
+
+	var err error
+
+	if report.SizeBefore, err = DiskUsage(b.path); err != nil {
+		return report, err
+	}
+
+	if err := b.db.Update(func(tx *bbolt.Tx) error { return dropBoltSelfReferences(tx, &report) }); err != nil {
+		return report, fmt.Errorf("%w", err)
+	}
+
+	if err := b.rewrite(); err != nil {
+		return report, err
+	}
+
+	if report.SizeAfter, err = DiskUsage(b.path); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func dropBoltSelfReferences(tx *bbolt.Tx, report *CompactReport) error {
+	bucket := tx.Bucket(boltNodes)
+
+	// a bucket cannot be modified while it is iterated, the compacted sets are written after
+	compacted := map[string][]byte{}
+
+	err := bucket.ForEach(func(rawKey, rawNodes []byte) error {
+		report.Nodes++
+
+		key, err := decodeKey(rawKey)
+		if err != nil {
+			return err
+		}
+
+		values, err := decode(rawNodes)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		values, changed := compactSet(key, values)
+		if !changed {
+			return nil
+		}
+
+		report.SelfReferences++
+
+		if compacted[string(rawKey)], err = encode(values); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for rawKey, rawNodes := range compacted {
+		if err := bucket.Put([]byte(rawKey), rawNodes); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// rewrite copies the silo into a new file, then replaces the file of the silo with it. The mutex must be held.
+func (b *BackendBolt) rewrite() error {
+	path := b.path + ".compact"
+
+	target, err := bbolt.Open(path, boltFileMode, boltOptions(false))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := bbolt.Compact(target, b.db, compactTxSize); err != nil {
+		target.Close()
+		os.Remove(path)
+
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := target.Close(); err != nil {
+		os.Remove(path)
+
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := os.Rename(path, b.path); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if b.db, err = bbolt.Open(b.path, boltFileMode, boltOptions(false)); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -37,6 +37,11 @@ func NewDataRowReaderJSONLine() (*DataRowReaderJSONLine, error) {
 	return &DataRowReaderJSONLine{decoder: json.NewDecoder(os.Stdin)}, nil
 }
 
+// NewDataRowReaderJSONLineFrom returns a reader of rows from input.
+func NewDataRowReaderJSONLineFrom(input io.Reader) *DataRowReaderJSONLine {
+	return &DataRowReaderJSONLine{decoder: json.NewDecoder(input)}
+}
+
 func NewDataRowReaderJSONLineFromFile(filename string) (*DataRowReaderJSONLine, error) {
 	source, err := os.Open(filename)
 	if err != nil {
@@ -59,6 +64,22 @@ func (drr *DataRowReaderJSONLine) ReadDataRow() (silo.DataRow, error) {
 	return nil, nil
 }
 
+// SkipDataRows reads count rows without decoding them into data rows.
+func (drr *DataRowReaderJSONLine) SkipDataRows(count int) (int, error) {
+	for skipped := 0; skipped < count; skipped++ {
+		if !drr.decoder.More() {
+			return skipped, nil
+		}
+
+		var raw json.RawMessage
+		if err := drr.decoder.Decode(&raw); err != nil {
+			return skipped, fmt.Errorf("%w", err)
+		}
+	}
+
+	return count, nil
+}
+
 func (drr *DataRowReaderJSONLine) Close() error {
 	return nil
 }
@@ -93,6 +114,25 @@ func (drr *DataRowReaderWriterJSONLine) ReadDataRow() (silo.DataRow, error) {
 	return nil, nil
 }
 
+// SkipDataRows passes count lines through to the output without decoding them.
+func (drr *DataRowReaderWriterJSONLine) SkipDataRows(count int) (int, error) {
+	for skipped := 0; skipped < count; skipped++ {
+		if !drr.input.Scan() {
+			if err := drr.input.Err(); err != nil {
+				return skipped, fmt.Errorf("%w", err)
+			}
+
+			return skipped, nil
+		}
+
+		if err := drr.writeLine(); err != nil {
+			return skipped, err
+		}
+	}
+
+	return count, nil
+}
+
 func (drr *DataRowReaderWriterJSONLine) writeLine() error {
 	if _, err := drr.output.Write(drr.input.Bytes()); err != nil {
 		return fmt.Errorf("%w", err)
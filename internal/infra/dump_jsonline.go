@@ -20,25 +20,55 @@
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/cgi-fr/silo/pkg/silo"
 )
 
-type DumpJSONLine struct{}
+type DumpJSONLine struct {
+	output     io.Writer
+	withStatus bool
+}
 
 func NewDumpJSONLine() *DumpJSONLine {
-	return &DumpJSONLine{}
+	return &DumpJSONLine{output: os.Stdout, withStatus: false}
+}
+
+// NewDumpJSONLineWithStatus returns a writer that adds the entity status and the per-key counts to each line.
+func NewDumpJSONLineWithStatus() *DumpJSONLine {
+	return &DumpJSONLine{output: os.Stdout, withStatus: true}
+}
+
+// NewDumpJSONLineTo returns a writer that writes lines to output instead of stdout.
+func NewDumpJSONLineTo(output io.Writer, withStatus bool) *DumpJSONLine {
+	return &DumpJSONLine{output: output, withStatus: withStatus}
 }
 
-func (d *DumpJSONLine) Write(node silo.DataNode, uuid string) error {
+func (d *DumpJSONLine) Write(node silo.DataNode, entity silo.EntitySummary) error {
 	line := struct {
-		UUID string `json:"uuid"`
-		ID   string `json:"id"`
-		Key  any    `json:"key"`
+		UUID       string         `json:"uuid"`
+		ID         string         `json:"id,omitempty"`
+		Key        any            `json:"key,omitempty"`
+		Status     silo.Status    `json:"status,omitempty"`
+		Counts     map[string]int `json:"counts,omitempty"`
+		Attributes map[string]any `json:"attributes,omitempty"`
+		Replaces   []string       `json:"replaces,omitempty"`
+		Removed    bool           `json:"removed,omitempty"`
 	}{
-		UUID: uuid,
-		ID:   node.Key,
-		Key:  node.Data,
+		UUID:       entity.UUID,
+		ID:         node.Key,
+		Key:        node.Data,
+		Status:     "",
+		Counts:     nil,
+		Attributes: entity.Attributes,
+		Replaces:   entity.Replaces,
+		Removed:    entity.Removed,
+	}
+
+	if d.withStatus {
+		line.Status = entity.Status
+		line.Counts = entity.Counts
 	}
 
 	bytes, err := json.Marshal(line)
@@ -46,7 +76,9 @@ func (d *DumpJSONLine) Write(node silo.DataNode, uuid string) error {
 		return fmt.Errorf("%w", err)
 	}
 
-	fmt.Println(string(bytes))
+	if _, err := fmt.Fprintln(d.output, string(bytes)); err != nil {
+		return fmt.Errorf("%w", err)
+	}
 
 	return nil
 }
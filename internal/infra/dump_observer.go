@@ -20,6 +20,7 @@
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cgi-fr/silo/pkg/silo"
@@ -32,6 +33,8 @@ type DumpObserver struct {
 	countConsistent   int
 	countInconsistent int
 	countEmpty        int
+	countCustom       map[silo.Status]int
+	customStatuses    []silo.Status
 	bar               *progressbar.ProgressBar
 }
 
@@ -54,14 +57,16 @@ func NewDumpObserver() *DumpObserver {
 		countConsistent:   0,
 		countInconsistent: 0,
 		countEmpty:        0,
+		countCustom:       map[silo.Status]int{},
+		customStatuses:    []silo.Status{},
 		bar:               pgb,
 	}
 }
 
-func (o *DumpObserver) Entity(status silo.Status, _ map[string]int) {
+func (o *DumpObserver) Entity(entity silo.EntitySummary) {
 	o.countTotal++
 
-	switch status {
+	switch entity.Status {
 	case silo.StatusEntityComplete:
 		o.countComplete++
 	case silo.StatusEntityConsistent:
@@ -70,16 +75,28 @@ func (o *DumpObserver) Entity(status silo.Status, _ map[string]int) {
 		o.countInconsistent++
 	case silo.StatusEntityEmpty:
 		o.countEmpty++
+	default:
+		if _, seen := o.countCustom[entity.Status]; !seen {
+			o.customStatuses = append(o.customStatuses, entity.Status)
+		}
+
+		o.countCustom[entity.Status]++
 	}
 
 	_ = o.bar.Add(1)
 
-	o.bar.Describe(fmt.Sprintf("Dumped %d entities / complete=%d / consistent=%d / inconsistent=%d / empty=%d",
+	custom := &strings.Builder{}
+	for _, status := range o.customStatuses {
+		fmt.Fprintf(custom, " / %s=%d", status, o.countCustom[status])
+	}
+
+	o.bar.Describe(fmt.Sprintf("Dumped %d entities / complete=%d / consistent=%d / inconsistent=%d / empty=%d%s",
 		o.countTotal,
 		o.countComplete,
 		o.countConsistent,
 		o.countInconsistent,
-		o.countEmpty))
+		o.countEmpty,
+		custom.String()))
 }
 
 func (o *DumpObserver) Close() {
@@ -0,0 +1,316 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+)
+
+// ExportFormat and ExportVersion identify the portable format, the version is increased on breaking changes.
+const (
+	ExportFormat  = "silo"
+	ExportVersion = 1
+)
+
+var (
+	ErrUnsupportedExport = errors.New("unsupported export")
+	ErrUnsupportedValue  = errors.New("unsupported value")
+)
+
+// The portable format is a JSONLine stream : a header line with the metadata of the silo (scans and checkpoints),
+// then one line per node with the set of its links.
+//
+//	{"format":"silo","version":1,"metadata":{"scans":[]}}
+//	{"node":{"key":"ID","type":"string","value":"1"},"links":[{"key":"EMAIL","type":"string","value":"a@b.c"}]}
+type exportHeader struct {
+	Format   string         `json:"format"`
+	Version  int            `json:"version"`
+	Metadata *silo.Metadata `json:"metadata,omitempty"`
+}
+
+type exportLine struct {
+	Node  exportNode   `json:"node"`
+	Links []exportNode `json:"links"`
+}
+
+type exportNode struct {
+	Key string `json:"key"`
+	exportValue
+}
+
+// exportValue keeps the Go type of the value, so it is restored exactly on import and its links match the links of
+// the same value in an existing silo. Numbers are typed by their Go kind (e.g. "int64", "float32").
+type exportValue struct {
+	Type     string          `json:"type"`
+	Sequence int64           `json:"sequence,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// Export writes all nodes and links of backend to output, it returns the number of exported nodes.
+func Export(backend Storage, output io.Writer) (int, error) {
+	writer := bufio.NewWriter(output)
+	encoder := json.NewEncoder(writer)
+	count := 0
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return count, err
+	}
+
+	if err := encoder.Encode(exportHeader{Format: ExportFormat, Version: ExportVersion, Metadata: &metadata}); err != nil {
+		return count, fmt.Errorf("%w", err)
+	}
+
+	err = backend.ForEach(func(key silo.DataNode, values []silo.DataNode) error {
+		line := exportLine{Node: exportNode{}, Links: make([]exportNode, 0, len(values))}
+
+		var err error
+
+		if line.Node, err = newExportNode(key); err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			link, err := newExportNode(value)
+			if err != nil {
+				return err
+			}
+
+			line.Links = append(line.Links, link)
+		}
+
+		count++
+
+		return encoder.Encode(line) //nolint:wrapcheck
+	})
+	if err != nil {
+		return count, fmt.Errorf("%w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return count, fmt.Errorf("%w", err)
+	}
+
+	return count, nil
+}
+
+// Import reads nodes and links exported by Export and adds them to backend, it returns the number of imported nodes.
+func Import(backend Storage, input io.Reader) (int, error) {
+	decoder := json.NewDecoder(input)
+	count := 0
+
+	var header exportHeader
+	if err := decoder.Decode(&header); err != nil {
+		return count, fmt.Errorf("%w : %w", ErrUnsupportedExport, err)
+	}
+
+	if header.Format != ExportFormat || header.Version < 1 || header.Version > ExportVersion {
+		return count, fmt.Errorf("%w : format %q version %d", ErrUnsupportedExport, header.Format, header.Version)
+	}
+
+	for decoder.More() {
+		var line exportLine
+		if err := decoder.Decode(&line); err != nil {
+			return count, fmt.Errorf("%w", err)
+		}
+
+		key, err := line.Node.dataNode()
+		if err != nil {
+			return count, err
+		}
+
+		values := make([]silo.DataNode, 0, len(line.Links))
+
+		for _, link := range line.Links {
+			value, err := link.dataNode()
+			if err != nil {
+				return count, err
+			}
+
+			values = append(values, value)
+		}
+
+		if err := storeAll(backend, key, values...); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	if header.Metadata != nil {
+		return count, appendMetadata(backend, *header.Metadata)
+	}
+
+	return count, nil
+}
+
+func newExportNode(node silo.DataNode) (exportNode, error) {
+	value, err := newExportValue(node.Data)
+
+	return exportNode{Key: node.Key, exportValue: value}, err
+}
+
+func newExportValue(data any) (exportValue, error) {
+	result := exportValue{Type: "", Sequence: 0, Value: nil}
+
+	switch tvalue := data.(type) {
+	case string:
+		result.Type = "string"
+	case bool:
+		result.Type = "bool"
+	case int, int64, int32, int16, int8, uint, uint64, uint32, uint16, uint8, float32, float64:
+		result.Type = reflect.TypeOf(data).Kind().String()
+	case nil:
+		result.Type = "null"
+	case silo.CompositeValue:
+		result.Type = "composite"
+		result.Value = json.RawMessage(tvalue)
+
+		return result, nil
+	case silo.AttributeValue:
+		attribute, err := newExportValue(tvalue.Value)
+		if err != nil {
+			return result, err
+		}
+
+		result.Type = "attribute"
+		result.Sequence = tvalue.Sequence
+		data = attribute
+	case silo.SoftValue:
+		soft, err := newExportValue(tvalue.Value)
+		if err != nil {
+			return result, err
+		}
+
+		result.Type = "soft"
+		data = soft
+	default:
+		return result, fmt.Errorf("%w : %v", ErrUnsupportedValue, reflect.TypeOf(data))
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return result, fmt.Errorf("%w", err)
+	}
+
+	result.Value = raw
+
+	return result, nil
+}
+
+func (n exportNode) dataNode() (silo.DataNode, error) {
+	data, err := n.data()
+
+	return silo.DataNode{Key: n.Key, Data: data}, err
+}
+
+func (v exportValue) data() (any, error) {
+	var err error
+
+	switch v.Type {
+	case "string":
+		var value string
+		err = json.Unmarshal(v.Value, &value)
+
+		return value, wrapError(err)
+	case "bool":
+		var value bool
+		err = json.Unmarshal(v.Value, &value)
+
+		return value, wrapError(err)
+	case "int", "int64", "int32", "int16", "int8", "uint", "uint64", "uint32", "uint16", "uint8", "float32", "float64":
+		return numberData(v.Type, v.Value)
+	case "null":
+		return nil, nil
+	case "composite":
+		return silo.CompositeValue(v.Value), nil
+	case "attribute":
+		var attribute exportValue
+		if err := json.Unmarshal(v.Value, &attribute); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		value, err := attribute.data()
+
+		return silo.AttributeValue{Sequence: v.Sequence, Value: value}, err
+	case "soft":
+		var soft exportValue
+		if err := json.Unmarshal(v.Value, &soft); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		value, err := soft.data()
+
+		return silo.SoftValue{Value: value}, err
+	default:
+		return nil, fmt.Errorf("%w : type %q", ErrUnsupportedValue, v.Type)
+	}
+}
+
+// numberData decodes a number of the given Go kind.
+func numberData(kind string, raw json.RawMessage) (any, error) {
+	var value any
+
+	switch kind {
+	case "int":
+		value = new(int)
+	case "int64":
+		value = new(int64)
+	case "int32":
+		value = new(int32)
+	case "int16":
+		value = new(int16)
+	case "int8":
+		value = new(int8)
+	case "uint":
+		value = new(uint)
+	case "uint64":
+		value = new(uint64)
+	case "uint32":
+		value = new(uint32)
+	case "uint16":
+		value = new(uint16)
+	case "uint8":
+		value = new(uint8)
+	case "float32":
+		value = new(float32)
+	default:
+		value = new(float64)
+	}
+
+	if err := json.Unmarshal(raw, value); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return reflect.ValueOf(value).Elem().Interface(), nil
+}
+
+func wrapError(err error) error {
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"github.com/cgi-fr/silo/pkg/silo"
+)
+
+// Info summarizes the content of a silo.
+type Info struct {
+	Keys       map[string]int `json:"keys"`
+	Nodes      int            `json:"nodes"`
+	Links      int            `json:"links"`
+	SoftLinks  int            `json:"softLinks"`
+	Attributes int            `json:"attributes"`
+	Metadata   silo.Metadata  `json:"metadata"`
+}
+
+func (b Backend) Info() (Info, error) {
+	return ReadInfo(b)
+}
+
+// ReadInfo counts the distinct values of each key and the links stored in the silo, links are counted once.
+func ReadInfo(backend Storage) (Info, error) {
+	info := Info{Keys: map[string]int{}, Nodes: 0, Links: 0, SoftLinks: 0, Attributes: 0, Metadata: silo.Metadata{}}
+
+	var err error
+
+	if info.Metadata, err = backend.Metadata(); err != nil {
+		return info, err
+	}
+
+	links, softLinks := 0, 0
+
+	err = backend.ForEach(func(key silo.DataNode, values []silo.DataNode) error {
+		info.Keys[key.Key]++
+		info.Nodes++
+
+		for _, value := range values {
+			switch {
+			case value.IsAttribute():
+				info.Attributes++
+			case value.IsSoft():
+				softLinks++
+			case value != key:
+				links++
+			}
+		}
+
+		return nil
+	})
+
+	// links are stored in both directions
+	info.Links = links / 2         //nolint:gomnd
+	info.SoftLinks = softLinks / 2 //nolint:gomnd
+
+	return info, err
+}
@@ -0,0 +1,255 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/dustin/go-humanize"
+)
+
+// DefaultScheme is the scheme of locations given without scheme, such as a plain path.
+const DefaultScheme = "pebble"
+
+var (
+	ErrUnknownBackend    = errors.New("unknown backend")
+	ErrInvalidBackendURI = errors.New("invalid backend URI")
+)
+
+// Storage is implemented by backends opened through the registry : besides links, they persist the metadata of the
+// silo and iterate over all nodes without a snapshot, as required by the info, export and merge commands.
+type Storage interface {
+	silo.Backend
+	silo.MetadataBackend
+	ForEach(fn func(key silo.DataNode, values []silo.DataNode) error) error
+}
+
+// storeAll adds all values to the set of key, with a single write if the backend supports it.
+func storeAll(backend Storage, key silo.DataNode, values ...silo.DataNode) error {
+	if batcher, ok := backend.(interface {
+		StoreAll(key silo.DataNode, values ...silo.DataNode) error
+	}); ok {
+		return batcher.StoreAll(key, values...) //nolint:wrapcheck
+	}
+
+	for _, value := range values {
+		if err := backend.Store(key, value); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// appendMetadata adds the scan records and the checkpoints of other to the metadata of the silo.
+func appendMetadata(backend Storage, other silo.Metadata) error {
+	if appender, ok := backend.(interface {
+		AppendMetadata(other silo.Metadata) error
+	}); ok {
+		return appender.AppendMetadata(other) //nolint:wrapcheck
+	}
+
+	if len(other.Scans) == 0 && len(other.Checkpoints) == 0 {
+		return nil
+	}
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	metadata.Append(other)
+
+	if err := backend.SetMetadata(metadata); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Opener opens the backend at the location of an URI, with the parameters given in its query.
+type Opener func(location string, params url.Values, readOnly bool) (Storage, error)
+
+//nolint:gochecknoglobals
+var (
+	registry = map[string]Opener{
+		"pebble":  openPebble,
+		"mem":     openMemory,
+		"sqlite":  openSQLite,
+		"bolt":    openBolt,
+		"sharded": openSharded,
+	}
+	registryMutex = sync.RWMutex{}
+)
+
+// Register makes a backend available under the given scheme, it replaces any backend registered with this scheme.
+func Register(scheme string, opener Opener) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry[scheme] = opener
+}
+
+// Schemes returns the sorted schemes of registered backends.
+func Schemes() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// URI locates a silo, as scheme://location?param=value.
+type URI struct {
+	Scheme   string
+	Location string
+	Params   url.Values
+}
+
+// ParseURI parses uri, a location without scheme (such as a plain path) uses the given default scheme.
+func ParseURI(uri string, defaultScheme string) (URI, error) {
+	scheme, rest, found := strings.Cut(uri, "://")
+	if !found {
+		scheme, rest = defaultScheme, uri
+	}
+
+	location, query, _ := strings.Cut(rest, "?")
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return URI{}, fmt.Errorf("%w %q : %w", ErrInvalidBackendURI, uri, err) //nolint:exhaustruct
+	}
+
+	return URI{Scheme: scheme, Location: location, Params: params}, nil
+}
+
+func (u URI) String() string {
+	if len(u.Params) == 0 {
+		return u.Scheme + "://" + u.Location
+	}
+
+	return u.Scheme + "://" + u.Location + "?" + u.Params.Encode()
+}
+
+// Open opens the backend located by uri with the opener registered for its scheme.
+func Open(uri URI, readOnly bool) (Storage, error) { //nolint:ireturn
+	registryMutex.RLock()
+	opener, ok := registry[uri.Scheme]
+	registryMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q, available backends are %s", ErrUnknownBackend, uri.Scheme,
+			strings.Join(Schemes(), ", "))
+	}
+
+	backend, err := opener(uri.Location, uri.Params, readOnly)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %v : %w", uri, err)
+	}
+
+	return backend, nil
+}
+
+// openPebble opens a pebble database, the snapshot parameter selects the dump strategy :
+// iterate-once (default, a single ordered iteration), full (loads the whole silo in memory),
+// spill (like iterate-once, within the memory budget parameter) or auto (one of full, iterate-once and spill,
+// chosen from the size of the silo and the available memory). indexed is an alias of iterate-once, kept for
+// compatibility since the indexed batch it named was replaced by a pebble snapshot.
+func openPebble(location string, params url.Values, readOnly bool) (Storage, error) { //nolint:ireturn
+	return openPebbleShare(location, params, readOnly, 1)
+}
+
+// openPebbleShare opens a pebble database sharing the memory of the process with other databases, the memory
+// budget and the available memory considered by the auto strategy are divided by the number of shares.
+func openPebbleShare(location string, params url.Values, readOnly bool, shares int) (Storage, error) { //nolint:ireturn
+	var (
+		backend Backend
+		err     error
+	)
+
+	if readOnly {
+		backend, err = NewBackendReadOnly(location)
+	} else {
+		backend, err = NewBackend(location)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch snapshot := params.Get("snapshot"); snapshot {
+	case "", "iterate-once", "indexed":
+		return backend, nil
+	case "full":
+		return BackendFull{backend}, nil
+	case "spill":
+		budget, err := parseBudget(params.Get("budget"))
+		if err != nil {
+			backend.Close()
+
+			return nil, err
+		}
+
+		return BackendSpill{backend, max(budget/shares, 1)}, nil
+	case "auto":
+		storage, err := autoStrategy(backend, shares)
+		if err != nil {
+			backend.Close()
+
+			return nil, err
+		}
+
+		return storage, nil
+	default:
+		backend.Close()
+
+		return nil, fmt.Errorf("%w : unknown snapshot strategy %q", ErrInvalidBackendURI, snapshot)
+	}
+}
+
+// parseBudget reads a memory budget such as 512MiB or 1GB, an empty budget is the default one.
+func parseBudget(value string) (int, error) {
+	if value == "" {
+		return DefaultMemoryBudget, nil
+	}
+
+	budget, err := humanize.ParseBytes(value)
+	if err != nil || budget == 0 || budget > math.MaxInt {
+		return 0, fmt.Errorf("%w : invalid memory budget %q", ErrInvalidBackendURI, value)
+	}
+
+	return int(budget), nil
+}
+
+// openMemory returns an empty in-memory backend, its content is lost when the process ends.
+func openMemory(_ string, _ url.Values, _ bool) (Storage, error) { //nolint:ireturn
+	return silo.NewBackendInMemory(), nil
+}
@@ -0,0 +1,471 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cgi-fr/silo/internal/infra"
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cgi-fr/silo/pkg/silo/silotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files") //nolint:gochecknoglobals
+
+// variant is a registered backend, with the query parameters selecting one of its strategies.
+type variant struct {
+	scheme  string
+	params  string
+	options []silotest.Option
+}
+
+func (v variant) String() string {
+	return v.scheme + "?" + v.params
+}
+
+// variants returns each registered backend, and each snapshot strategy of pebble.
+func variants() []variant {
+	variants := []variant{}
+
+	for _, scheme := range infra.Schemes() {
+		variants = append(variants, variant{scheme: scheme, params: "", options: nil})
+	}
+
+	for _, snapshot := range []string{"full", "iterate-once", "spill", "spill&budget=1B", "auto"} {
+		variants = append(variants, variant{scheme: "pebble", params: "snapshot=" + snapshot, options: nil})
+	}
+
+	variants = append(variants, variant{scheme: "sharded", params: "shards=3&snapshot=iterate-once", options: nil})
+
+	return variants
+}
+
+func (v variant) open(t *testing.T) infra.Storage { //nolint:ireturn
+	t.Helper()
+
+	location := v.scheme + "://" + filepath.Join(t.TempDir(), "silo")
+	if v.params != "" {
+		location += "?" + v.params
+	}
+
+	uri, err := infra.ParseURI(location, infra.DefaultScheme)
+	require.NoError(t, err)
+
+	backend, err := infra.Open(uri, false)
+	require.NoError(t, err)
+
+	return backend
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			silotest.TestBackend(t, func(t *testing.T) silo.Backend {
+				t.Helper()
+
+				return variant.open(t)
+			}, variant.options...)
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			testStorage(t, backend)
+		})
+	}
+}
+
+// testStorage checks the methods required by the info, export and merge commands.
+func testStorage(t *testing.T, backend infra.Storage) {
+	t.Helper()
+
+	rows := []silo.DataRow{{"ID": 1, "EMAIL": "a"}, {"ID": 2, "EMAIL": "b"}, {"ID": 2, "EMAIL": "c"}, {"ID": 3}}
+
+	driver := silo.NewDriver(backend, nil, silo.WithSource("conformance"))
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+	require.Len(t, metadata.Scans, 1)
+	assert.Equal(t, "conformance", metadata.Scans[0].Source)
+
+	info, err := infra.ReadInfo(backend)
+
+	require.NoError(t, err)
+	assert.Equal(t, 6, info.Nodes)
+	assert.Equal(t, 3, info.Links)
+}
+
+// TestCompact checks that compaction drops redundant self references without altering the entities.
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			// ID 1 is stored with a self reference, which becomes redundant when it is linked to EMAIL a
+			rows := []silo.DataRow{{"ID": 1}, {"ID": 1, "EMAIL": "a"}, {"ID": 2}, {"ID": 3, "EMAIL": "b"}}
+
+			driver := silo.NewDriver(backend, nil, silo.WithAttributes([]string{"NAME"}))
+			require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+			require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory([]silo.DataRow{{"ID": 2, "NAME": "x"}})))
+
+			entities := silotest.Entities(t, backend)
+
+			report, err := infra.Compact(backend)
+			if variant.scheme == "mem" {
+				require.ErrorIs(t, err, infra.ErrCompactNotSupported)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, report.SelfReferences, "the self reference next to an attribute is kept")
+			assert.Equal(t, 5, report.Nodes)
+			assert.Positive(t, report.SizeAfter)
+			assert.Equal(t, entities, silotest.Entities(t, backend))
+
+			nodes, err := backend.Get(silo.DataNode{Key: "ID", Data: 2})
+
+			require.NoError(t, err)
+			assert.Len(t, nodes, 2)
+
+			report, err = infra.Compact(backend)
+
+			require.NoError(t, err)
+			assert.Zero(t, report.SelfReferences)
+
+			// the row with ID 1 alone still contributes ID 1 once its self reference is dropped
+			require.NoError(t, driver.Retract(silo.NewDataRowReaderInMemory(rows[1:2])))
+
+			nodes, err = backend.Get(silo.DataNode{Key: "ID", Data: 1})
+
+			require.NoError(t, err)
+			assert.Equal(t, []silo.DataNode{{Key: "ID", Data: 1}}, nodes)
+		})
+	}
+}
+
+// TestCheck checks that asymmetric links and inconsistent metadata are found, and that asymmetric links are repaired.
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			rows := []silo.DataRow{{"ID": 1, "EMAIL": "a", "NAME": "x"}, {"ID": 2}}
+
+			driver := silo.NewDriver(backend, nil, silo.WithAttributes([]string{"NAME"}))
+			require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+			report, err := infra.Check(backend, false)
+
+			require.NoError(t, err)
+			assert.Empty(t, report.Issues)
+			assert.Equal(t, 3, report.Nodes)
+			assert.Equal(t, 2, report.Links)
+
+			// a scan stopped between the two writes of a link, and a soft link stored in a single direction
+			id, email := silo.DataNode{Key: "ID", Data: 3}, silo.DataNode{Key: "EMAIL", Data: "c"}
+
+			require.NoError(t, backend.Store(id, email))
+			require.NoError(t, backend.Store(id, silo.DataNode{Key: "ID", Data: 2}.Soft()))
+
+			metadata, err := backend.Metadata()
+
+			require.NoError(t, err)
+
+			metadata.Checkpoints["crashed"] = silo.Checkpoint{Rows: 10} //nolint:exhaustruct
+
+			require.NoError(t, backend.SetMetadata(metadata))
+
+			report, err = infra.Check(backend, true)
+
+			require.NoError(t, err)
+			require.Len(t, report.Issues, 3)
+			assert.Equal(t, 0, report.Unrepaired(), "a scan to resume is not an inconsistency")
+
+			kinds := []string{}
+			for _, issue := range report.Issues {
+				kinds = append(kinds, issue.Kind)
+				assert.Equal(t, issue.Kind == infra.IssueIncomplete, issue.Info)
+			}
+
+			assert.ElementsMatch(t, []string{infra.IssueIncomplete, infra.IssueAsymmetric, infra.IssueAsymmetric}, kinds)
+
+			nodes, err := backend.Get(silo.DataNode{Key: "ID", Data: 2})
+
+			require.NoError(t, err)
+			assert.Contains(t, nodes, id.Soft())
+
+			report, err = infra.Check(backend, false)
+
+			require.NoError(t, err)
+			assert.Len(t, report.Issues, 1)
+		})
+	}
+}
+
+// TestGoldenEntities checks the exact entities dumped by every backend and strategy.
+func TestGoldenEntities(t *testing.T) {
+	t.Parallel()
+
+	golden := filepath.Join("testdata", "entities.golden.json")
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			input, err := os.Open(filepath.Join("testdata", "rows.jsonl"))
+			require.NoError(t, err)
+
+			defer input.Close()
+
+			reader := infra.NewDataRowReaderJSONLineFrom(input)
+			require.NoError(t, silo.NewDriver(backend, nil).Scan(reader))
+
+			entities := silotest.Entities(t, backend)
+
+			// go test -run TestGoldenEntities -update rewrites the golden file from the default backend
+			if *update {
+				if variant.scheme != infra.DefaultScheme || variant.params != "" {
+					t.Skip("updating golden file")
+				}
+
+				content, err := json.MarshalIndent(entities, "", "  ")
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(golden, append(content, '\n'), 0o600))
+			}
+
+			content, err := os.ReadFile(golden)
+			require.NoError(t, err)
+
+			expected := [][]string{}
+			require.NoError(t, json.Unmarshal(content, &expected))
+
+			assert.Equal(t, expected, entities)
+		})
+	}
+}
+
+// scanningObserver scans rows when the first entity is dumped, while the other entities are still to be read.
+type scanningObserver struct {
+	t       *testing.T
+	backend silo.Backend
+	rows    []silo.DataRow
+}
+
+func (o *scanningObserver) Entity(_ silo.EntitySummary) {
+	if o.rows != nil {
+		require.NoError(o.t, silo.NewDriver(o.backend, nil).Scan(silo.NewDataRowReaderInMemory(o.rows)))
+
+		o.rows = nil
+	}
+}
+
+// groupsWriter collects dumped nodes formatted as key=type(value), grouped by entity.
+type groupsWriter struct {
+	entities map[string][]string
+}
+
+func (w *groupsWriter) Write(node silo.DataNode, entity silo.EntitySummary) error {
+	w.entities[entity.UUID] = append(w.entities[entity.UUID], node.String())
+
+	return nil
+}
+
+func (w *groupsWriter) Close() error {
+	return nil
+}
+
+func (w *groupsWriter) groups() [][]string {
+	groups := make([][]string, 0, len(w.entities))
+
+	for _, nodes := range w.entities {
+		sort.Strings(nodes)
+
+		groups = append(groups, nodes)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	return groups
+}
+
+func TestScanDuringDump(t *testing.T) {
+	t.Parallel()
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			rows := []silo.DataRow{{"ID": 1, "EMAIL": "a"}, {"ID": 2, "EMAIL": "b"}, {"ID": 3, "EMAIL": "c"}}
+			require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+			// these rows merge all entities, and add a new one
+			observer := &scanningObserver{t: t, backend: backend, rows: []silo.DataRow{
+				{"ID": 1, "EMAIL": "b"}, {"ID": 2, "EMAIL": "c"}, {"ID": 4, "EMAIL": "d"},
+			}}
+
+			writer := &groupsWriter{entities: map[string][]string{}}
+			require.NoError(t, silo.NewDriver(backend, writer).Dump(observer))
+
+			assert.Equal(t, [][]string{
+				{"EMAIL=string(a)", "ID=number(1)"},
+				{"EMAIL=string(b)", "ID=number(2)"},
+				{"EMAIL=string(c)", "ID=number(3)"},
+			}, writer.groups(), "the dump reads the silo as it was when the dump started")
+
+			assert.Equal(t, [][]string{
+				{"EMAIL=string(a)", "EMAIL=string(b)", "EMAIL=string(c)", "ID=number(1)", "ID=number(2)", "ID=number(3)"},
+				{"EMAIL=string(d)", "ID=number(4)"},
+			}, silotest.Entities(t, backend))
+		})
+	}
+}
+
+// summariesObserver collects the summaries of dumped entities by uuid.
+type summariesObserver map[string]silo.EntitySummary
+
+func (o summariesObserver) Entity(entity silo.EntitySummary) {
+	o[entity.UUID] = entity
+}
+
+// TestDumpChangedRemoval checks that an entity whose nodes were all deleted is removed by the next dump of changes.
+func TestDumpChangedRemoval(t *testing.T) {
+	t.Parallel()
+
+	for _, variant := range variants() {
+		variant := variant
+
+		t.Run(variant.String(), func(t *testing.T) {
+			t.Parallel()
+
+			backend := variant.open(t)
+			defer backend.Close()
+
+			driver := silo.NewDriver(backend, &groupsWriter{entities: map[string][]string{}})
+			dumpChanged := func() summariesObserver {
+				observer := summariesObserver{}
+				require.NoError(t, driver.DumpChanged(context.Background(), observer))
+
+				return observer
+			}
+
+			rows := []silo.DataRow{{"ID": 1}, {"ID": 2, "EMAIL": "a"}}
+			require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+			previous := map[int]string{}
+			for uuid, entity := range dumpChanged() {
+				previous[entity.Counts["EMAIL"]] = uuid
+			}
+
+			// ID 2 is left alone and replaces its entity, ID 1 was the only node of its entity
+			require.NoError(t, driver.Delete(silo.DataNode{Key: "ID", Data: 1}, silo.DataNode{Key: "EMAIL", Data: "a"}))
+
+			entities := dumpChanged()
+
+			require.Len(t, entities, 2)
+
+			removed := 0
+
+			for _, entity := range entities {
+				if entity.Removed {
+					removed++
+
+					assert.Equal(t, []string{previous[0]}, entity.Replaces)
+				} else {
+					assert.Equal(t, []string{previous[1]}, entity.Replaces)
+				}
+			}
+
+			assert.Equal(t, 1, removed)
+			assert.Empty(t, dumpChanged(), "a removal is dumped once")
+		})
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	t.Parallel()
+
+	uri, err := infra.ParseURI("path/to/silo", "mem")
+
+	require.NoError(t, err)
+	assert.Equal(t, "mem", uri.Scheme)
+	assert.Equal(t, "path/to/silo", uri.Location)
+
+	uri, err = infra.ParseURI("pebble:///tmp/silo?snapshot=full", infra.DefaultScheme)
+
+	require.NoError(t, err)
+	assert.Equal(t, "pebble", uri.Scheme)
+	assert.Equal(t, "/tmp/silo", uri.Location)
+	assert.Equal(t, "full", uri.Params.Get("snapshot"))
+	assert.Equal(t, "pebble:///tmp/silo?snapshot=full", uri.String())
+
+	_, err = infra.Open(infra.URI{Scheme: "unknown", Location: "", Params: nil}, false)
+
+	require.ErrorIs(t, err, infra.ErrUnknownBackend)
+
+	_, err = infra.Open(infra.URI{Scheme: "pebble", Location: t.TempDir(), Params: map[string][]string{
+		"snapshot": {"bogus"},
+	}}, false)
+
+	require.ErrorIs(t, err, infra.ErrInvalidBackendURI)
+
+	_, err = infra.Open(infra.URI{Scheme: "pebble", Location: t.TempDir(), Params: map[string][]string{
+		"snapshot": {"spill"}, "budget": {"lots"},
+	}}, false)
+
+	require.ErrorIs(t, err, infra.ErrInvalidBackendURI)
+}
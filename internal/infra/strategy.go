@@ -0,0 +1,224 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package infra
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/rs/zerolog/log"
+)
+
+// Estimated memory used by a map entry besides its key and value, when the whole silo is loaded by SnapshotFull.
+const fullEntryOverhead = 64
+
+// DefaultAvailableMemory is assumed when the memory available to the process cannot be read from the system.
+const DefaultAvailableMemory = 1 << 30
+
+// SizeEstimate is an estimation of the content of a pebble silo, computed from the properties of its tables
+// without reading them. Deleted and overwritten entries are counted until a compaction removes them, so the estimate
+// errs on the side of caution.
+type SizeEstimate struct {
+	Keys       uint64
+	KeyBytes   uint64
+	ValueBytes uint64
+}
+
+// Full is the estimated memory needed to load the whole silo, as the full strategy does.
+func (e SizeEstimate) Full() uint64 {
+	return e.KeyBytes + e.ValueBytes + e.Keys*fullEntryOverhead
+}
+
+// Visited is the estimated memory needed to remember every node as visited, as the iterate-once strategy does.
+func (e SizeEstimate) Visited() uint64 {
+	return e.KeyBytes + e.Keys*visitedEntryOverhead
+}
+
+// Estimate returns the estimated size of the silo, entries not flushed yet to tables are ignored as memtables are
+// small compared to the silos that do not fit in memory.
+func (b Backend) Estimate() (SizeEstimate, error) {
+	estimate := SizeEstimate{Keys: 0, KeyBytes: 0, ValueBytes: 0}
+
+	levels, err := b.db.SSTables(pebble.WithProperties())
+	if err != nil {
+		return estimate, fmt.Errorf("%w", err)
+	}
+
+	for _, tables := range levels {
+		for _, table := range tables {
+			estimate.Keys += table.Properties.NumEntries
+			estimate.KeyBytes += table.Properties.RawKeySize
+			estimate.ValueBytes += table.Properties.RawValueSize
+		}
+	}
+
+	return estimate, nil
+}
+
+// AvailableMemory returns the memory available to the process, the lowest of the memory available on the system
+// and of the memory left by the cgroup limit. It returns false if neither can be read, e.g. on other systems
+// than linux.
+func AvailableMemory() (uint64, bool) {
+	available, found := memInfoAvailable("/proc/meminfo")
+
+	if limit, ok := cgroupAvailable(); ok && (!found || limit < available) {
+		available, found = limit, true
+	}
+
+	return available, found
+}
+
+func memInfoAvailable(path string) (uint64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// MemAvailable:   12345678 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" && fields[2] == "kB" {
+			kilobytes, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, false
+			}
+
+			return kilobytes << 10, true //nolint:gomnd
+		}
+	}
+
+	return 0, false
+}
+
+// cgroupAvailable returns the memory left before the limit of the cgroup (v2 then v1) of the process.
+func cgroupAvailable() (uint64, bool) {
+	for _, files := range [][2]string{
+		{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory.current"},
+		{"/sys/fs/cgroup/memory/memory.limit_in_bytes", "/sys/fs/cgroup/memory/memory.usage_in_bytes"},
+	} {
+		limit, ok := readUint(files[0])
+		if !ok {
+			continue
+		}
+
+		usage, _ := readUint(files[1])
+		if usage > limit {
+			return 0, true
+		}
+
+		return limit - usage, true
+	}
+
+	return 0, false
+}
+
+// readUint reads a file holding a single number, "max" or an unset cgroup v1 limit are not read as a limit.
+func readUint(path string) (uint64, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(string(bytes.TrimSpace(content)), 10, 64)
+	if err != nil || value >= math.MaxInt64/4096*4096 {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// ChooseStrategy returns the fastest dump strategy whose memory needs fit in half of the available memory, the
+// other half is left to the entities being dumped, the writer and the pebble caches. The budget is the memory
+// budget of the spill strategy.
+func ChooseStrategy(estimate SizeEstimate, available uint64) (string, int) {
+	usable := available / 2 //nolint:gomnd
+
+	switch {
+	case estimate.Full() <= usable:
+		return "full", 0
+	case estimate.Visited() <= usable:
+		return "iterate-once", 0
+	default:
+		return "spill", int(min(max(usable/2, 1<<20), math.MaxInt)) //nolint:gomnd
+	}
+}
+
+// autoStrategy wraps the backend with the strategy chosen from its estimated size and its share of the available
+// memory.
+func autoStrategy(backend Backend, shares int) (Storage, error) { //nolint:ireturn
+	estimate, err := backend.Estimate()
+	if err != nil {
+		return nil, err
+	}
+
+	available, found := AvailableMemory()
+	if !found {
+		available = DefaultAvailableMemory
+	}
+
+	available /= uint64(shares)
+
+	strategy, budget := ChooseStrategy(estimate, available)
+
+	// a sharded silo logs the strategies of all its shards at once
+	event := log.Info()
+	if shares > 1 {
+		event = log.Debug()
+	}
+
+	event.
+		Uint64("keys", estimate.Keys).
+		Uint64("estimated-full", estimate.Full()).
+		Uint64("estimated-visited", estimate.Visited()).
+		Uint64("available-memory", available).
+		Bool("available-memory-known", found).
+		Str("strategy", strategy).
+		Int("budget", budget).
+		Msg("dump strategy selected")
+
+	switch strategy {
+	case "full":
+		return BackendFull{backend}, nil
+	case "iterate-once":
+		return BackendInterateOnce{backend}, nil
+	default:
+		return BackendSpill{backend, budget}, nil
+	}
+}
+
+// strategyOf returns the name of the dump strategy of storage.
+func strategyOf(storage Storage) string {
+	switch storage.(type) {
+	case BackendFull:
+		return "full"
+	case BackendSpill:
+		return "spill"
+	default:
+		return "iterate-once"
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package fuzzy provides blocking and similarity functions to find values that are probably the same despite typos.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	soundexLength       = 4
+	winklerPrefixLength = 4
+	winklerScaling      = 0.1
+)
+
+// Soundex returns the american soundex code of value as a single block, or no block if value has no letter.
+func Soundex(value string) []string {
+	code := make([]byte, 0, soundexLength)
+
+	var last byte
+
+	for _, char := range strings.ToUpper(value) {
+		if char > unicode.MaxASCII || !unicode.IsLetter(char) {
+			continue
+		}
+
+		digit := soundexDigit(byte(char))
+
+		if len(code) == 0 {
+			code = append(code, byte(char))
+			last = digit
+
+			continue
+		}
+
+		// H and W do not separate letters with the same code, vowels do
+		if char == 'H' || char == 'W' {
+			continue
+		}
+
+		if digit != '0' && digit != last {
+			code = append(code, digit)
+		}
+
+		last = digit
+
+		if len(code) == soundexLength {
+			break
+		}
+	}
+
+	if len(code) == 0 {
+		return []string{}
+	}
+
+	for len(code) < soundexLength {
+		code = append(code, '0')
+	}
+
+	return []string{string(code)}
+}
+
+func soundexDigit(char byte) byte {
+	switch char {
+	case 'B', 'F', 'P', 'V':
+		return '1'
+	case 'C', 'G', 'J', 'K', 'Q', 'S', 'X', 'Z':
+		return '2'
+	case 'D', 'T':
+		return '3'
+	case 'L':
+		return '4'
+	case 'M', 'N':
+		return '5'
+	case 'R':
+		return '6'
+	default:
+		return '0'
+	}
+}
+
+// NGrams returns a blocking function that puts a value in one block per distinct n-gram of its characters.
+func NGrams(n int) func(value string) []string {
+	return func(value string) []string {
+		runes := []rune(strings.ToLower(value))
+
+		if len(runes) == 0 {
+			return []string{}
+		}
+
+		if len(runes) <= n {
+			return []string{string(runes)}
+		}
+
+		seen := make(map[string]bool, len(runes)-n+1)
+		blocks := make([]string, 0, len(runes)-n+1)
+
+		for i := 0; i+n <= len(runes); i++ {
+			if gram := string(runes[i : i+n]); !seen[gram] {
+				seen[gram] = true
+				blocks = append(blocks, gram)
+			}
+		}
+
+		return blocks
+	}
+}
+
+// Levenshtein returns 1 minus the edit distance between a and b divided by the length of the longest one.
+func Levenshtein(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+
+	longest := max(len(runesA), len(runesB))
+	if longest == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return 1 - float64(previous[len(runesB)])/float64(longest)
+}
+
+// Jaro returns the Jaro similarity between a and b, between 0 (no similarity) and 1 (same values).
+func Jaro(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+
+	if len(runesA) == 0 && len(runesB) == 0 {
+		return 1
+	}
+
+	if len(runesA) == 0 || len(runesB) == 0 {
+		return 0
+	}
+
+	window := max(max(len(runesA), len(runesB))/2-1, 0) //nolint:gomnd
+
+	matchedA := make([]bool, len(runesA))
+	matchedB := make([]bool, len(runesB))
+	matches := 0
+
+	for i := range runesA {
+		for j := max(0, i-window); j < min(len(runesB), i+window+1); j++ {
+			if !matchedB[j] && runesA[i] == runesB[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+
+	for i, j := 0, 0; i < len(runesA); i++ {
+		if !matchedA[i] {
+			continue
+		}
+
+		for !matchedB[j] {
+			j++
+		}
+
+		if runesA[i] != runesB[j] {
+			transpositions++
+		}
+
+		j++
+	}
+
+	m := float64(matches)
+
+	return (m/float64(len(runesA)) + m/float64(len(runesB)) + (m-float64(transpositions)/2)/m) / 3 //nolint:gomnd
+}
+
+// JaroWinkler returns the Jaro similarity between a and b, boosted when both values share a common prefix.
+func JaroWinkler(a, b string) float64 {
+	jaro := Jaro(a, b)
+
+	runesA, runesB := []rune(a), []rune(b)
+	prefix := 0
+
+	for prefix < min(winklerPrefixLength, len(runesA), len(runesB)) && runesA[prefix] == runesB[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*winklerScaling*(1-jaro)
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package fuzzy_test
+
+import (
+	"testing"
+
+	"github.com/cgi-fr/silo/pkg/fuzzy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoundex(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"R163"}, fuzzy.Soundex("Robert"))
+	assert.Equal(t, []string{"R163"}, fuzzy.Soundex("Rupert"))
+	assert.Equal(t, []string{"A261"}, fuzzy.Soundex("Ashcraft"))
+	assert.Equal(t, []string{"T522"}, fuzzy.Soundex("Tymczak"))
+	assert.Equal(t, []string{"P236"}, fuzzy.Soundex("Pfister"))
+	assert.Equal(t, []string{"L000"}, fuzzy.Soundex("Lee"))
+	assert.Empty(t, fuzzy.Soundex("1234"))
+}
+
+func TestNGrams(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"jea", "ean"}, fuzzy.NGrams(3)("Jean"))
+	assert.Equal(t, []string{"al"}, fuzzy.NGrams(3)("Al"))
+	assert.Empty(t, fuzzy.NGrams(3)(""))
+}
+
+func TestSimilarities(t *testing.T) {
+	t.Parallel()
+
+	assert.InDelta(t, 0.944, fuzzy.Jaro("MARTHA", "MARHTA"), 0.001)
+	assert.InDelta(t, 0.961, fuzzy.JaroWinkler("MARTHA", "MARHTA"), 0.001)
+	assert.InDelta(t, 0.813, fuzzy.JaroWinkler("DIXON", "DICKSONX"), 0.001)
+	assert.InDelta(t, 1.0, fuzzy.JaroWinkler("same", "same"), 0.001)
+	assert.InDelta(t, 0.0, fuzzy.JaroWinkler("abc", "xyz"), 0.001)
+
+	assert.InDelta(t, 1-3.0/7.0, fuzzy.Levenshtein("kitten", "sitting"), 0.001)
+	assert.InDelta(t, 1.0, fuzzy.Levenshtein("", ""), 0.001)
+	assert.InDelta(t, 0.0, fuzzy.Levenshtein("abc", ""), 0.001)
+}
@@ -48,6 +48,20 @@ func (m Multimap[K, V]) Delete(key K) []V {
 	return values
 }
 
+// Remove a key/value pair from the multimap, the key is deleted if no value remains.
+func (m Multimap[K, V]) Remove(key K, value V) {
+	set, ok := m[key]
+	if !ok {
+		return
+	}
+
+	delete(set, value)
+
+	if len(set) == 0 {
+		delete(m, key)
+	}
+}
+
 // Get values associated to key.
 func (m Multimap[K, V]) Get(key K) []V {
 	set, ok := m[key]
@@ -17,24 +17,98 @@
 
 package silo
 
-import "errors"
+import (
+	"errors"
+	"sort"
+)
 
 type config struct {
-	include     map[string]bool
-	includeList []string
-	aliases     map[string]string
+	include       map[string]bool
+	includeList   []string
+	aliases       map[string]string
+	statuses      map[Status]bool
+	mandatory     map[string]bool
+	mandatoryList []string
+	optional      map[string]bool
+	cardinalities map[string]Cardinality
+	rules         []Rule
+	fuzzy         map[string]fuzzyMatcher
+	softLinks     bool
+	composites    []composite
+	hidden        map[string]bool
+	attributes    map[string]bool
+	policies      map[string]MergePolicy
+	source        string
+	version       string
+	checkpoint    int
+	resume        bool
+}
+
+type composite struct {
+	key   string
+	parts []string
 }
 
 func newConfig() *config {
 	config := config{
-		include:     map[string]bool{},
-		includeList: []string{},
-		aliases:     map[string]string{},
+		include:       map[string]bool{},
+		includeList:   []string{},
+		aliases:       map[string]string{},
+		statuses:      map[Status]bool{},
+		mandatory:     map[string]bool{},
+		mandatoryList: []string{},
+		optional:      map[string]bool{},
+		cardinalities: map[string]Cardinality{},
+		rules:         []Rule{},
+		fuzzy:         map[string]fuzzyMatcher{},
+		softLinks:     true,
+		composites:    []composite{},
+		hidden:        map[string]bool{},
+		attributes:    map[string]bool{},
+		policies:      map[string]MergePolicy{},
+		source:        "",
+		version:       "",
+		checkpoint:    DefaultCheckpointInterval,
+		resume:        false,
 	}
 
 	return &config
 }
 
+func (cfg *config) includeKey(key string) {
+	if _, exist := cfg.include[key]; !exist {
+		cfg.includeList = append(cfg.includeList, key)
+	}
+
+	cfg.include[key] = true
+}
+
+// includeConstrained includes the keys constrained by a cardinality or a rule, so that their counts are not always
+// zero. Without include all keys are already included. It is called once the membership is built, as included keys
+// are mandatory by default.
+func (cfg *config) includeConstrained() {
+	if len(cfg.include) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(cfg.cardinalities))
+	for key := range cfg.cardinalities {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		cfg.includeKey(key)
+	}
+
+	for _, rule := range cfg.rules {
+		for _, constraint := range rule.Constraints {
+			cfg.includeKey(constraint.Key)
+		}
+	}
+}
+
 func (cfg *config) validate() error {
 	var errs []error
 
@@ -44,9 +118,99 @@ func (cfg *config) validate() error {
 		}
 	}
 
+	for key := range cfg.mandatory {
+		if _, ok := cfg.optional[key]; ok {
+			errs = append(errs, &ConfigKeyIsMandatoryAndOptionalError{key: key})
+		}
+	}
+
+	for key, cardinality := range cfg.cardinalities {
+		if !cardinality.valid() {
+			errs = append(errs, &ConfigInvalidCardinalityError{key: key, cardinality: cardinality})
+		}
+	}
+
+	custom := map[Status]bool{}
+
+	for _, rule := range cfg.rules {
+		if rule.Status.builtin() {
+			errs = append(errs, &ConfigRuleStatusIsReservedError{status: rule.Status})
+		}
+
+		for _, constraint := range rule.Constraints {
+			if !constraint.valid() {
+				errs = append(errs, &ConfigInvalidCardinalityError{key: constraint.Key, cardinality: constraint.Cardinality})
+			}
+		}
+
+		custom[rule.Status] = true
+	}
+
+	for _, composite := range cfg.composites {
+		if len(composite.parts) == 0 {
+			errs = append(errs, &ConfigCompositeWithoutPartError{key: composite.key})
+		}
+	}
+
+	for key, policy := range cfg.policies {
+		if !policy.valid() {
+			errs = append(errs, &ConfigUnknownMergePolicyError{key: key, policy: policy})
+		}
+	}
+
+	for key, matcher := range cfg.fuzzy {
+		if matcher.blocking == nil || matcher.similarity == nil || matcher.threshold <= 0 || matcher.threshold > 1 {
+			errs = append(errs, &ConfigInvalidFuzzyMatchingError{key: key})
+		}
+	}
+
+	for status := range cfg.statuses {
+		if !status.builtin() && !custom[status] {
+			errs = append(errs, &ConfigDumpUnknownStatusError{status: status})
+		}
+	}
+
 	if len(errs) != 0 {
 		return errors.Join(errs...)
 	}
 
 	return nil
 }
+
+func (cfg *config) buildMembership() *membership {
+	mandatory := cfg.mandatoryList
+
+	// without explicit mandatory keys, every included key that is not optional is mandatory
+	if len(mandatory) == 0 {
+		mandatory = make([]string, 0, len(cfg.includeList))
+
+		for _, key := range cfg.includeList {
+			if _, optional := cfg.optional[key]; !optional {
+				mandatory = append(mandatory, key)
+			}
+		}
+	}
+
+	// a key with a minimal count is required for the entity to be complete
+	for key, cardinality := range cfg.cardinalities {
+		if _, exist := cfg.mandatory[key]; !exist && cardinality.Min > 0 && !contains(mandatory, key) {
+			mandatory = append(mandatory, key)
+		}
+	}
+
+	membership := newMembership(mandatory)
+	membership.cardinalities = cfg.cardinalities
+	membership.rules = cfg.rules
+
+	return membership
+}
+
+func contains(list []string, item string) bool {
+	for _, element := range list {
+		if element == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,28 +17,201 @@
 
 package silo
 
-import "github.com/cgi-fr/silo/pkg/multimap"
+import (
+	"sync"
 
+	"github.com/cgi-fr/silo/pkg/multimap"
+)
+
+// BackendInMemory is safe for concurrent use, its snapshots are copies of the links at the time of the call.
 type BackendInMemory struct {
-	links multimap.Multimap[DataNode, DataNode]
+	links    multimap.Multimap[DataNode, DataNode]
+	metadata Metadata
+	touched  map[DataNode]int64
+	entities map[DataNode]string
+	removed  map[string]int64
+	version  int64
+	mutex    sync.RWMutex
 }
 
 func NewBackendInMemory() *BackendInMemory {
 	return &BackendInMemory{
-		links: multimap.Multimap[DataNode, DataNode]{},
+		links:    multimap.Multimap[DataNode, DataNode]{},
+		metadata: Metadata{Scans: []ScanRecord{}, Checkpoints: map[string]Checkpoint{}, Compacted: false},
+		touched:  map[DataNode]int64{},
+		entities: map[DataNode]string{},
+		removed:  map[string]int64{},
+		version:  0,
+		mutex:    sync.RWMutex{},
 	}
 }
 
+// touch must be called with the write lock held.
+func (b *BackendInMemory) touch(node DataNode) {
+	b.version++
+	b.touched[node] = b.version
+}
+
+// erase forgets the change and the entity of a deleted node, so no trace of it is kept but the removal of its entity,
+// it must be called with the write lock held.
+func (b *BackendInMemory) erase(node DataNode) {
+	if uuid, found := b.entities[node]; found {
+		b.version++
+		b.removed[uuid] = b.version
+	}
+
+	delete(b.touched, node)
+	delete(b.entities, node)
+}
+
 func (b *BackendInMemory) Store(key DataNode, value DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
 	b.links.Add(key, value)
+	b.touch(key)
+
+	return nil
+}
+
+func (b *BackendInMemory) Get(key DataNode) ([]DataNode, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.links.Get(key), nil
+}
+
+func (b *BackendInMemory) Remove(key DataNode, value DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.links.Remove(key, value)
+
+	if b.links.Count(key) == 0 {
+		b.erase(key)
+	} else {
+		b.touch(key)
+	}
+
+	return nil
+}
+
+func (b *BackendInMemory) Delete(key DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.links.Delete(key)
+	b.erase(key)
 
 	return nil
 }
 
 func (b *BackendInMemory) Snapshot() Snapshot { //nolint:ireturn
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
 	return &BackendInMemory{
-		links: b.links.Copy(),
+		links:    b.links.Copy(),
+		metadata: b.metadata,
+		touched:  map[DataNode]int64{},
+		entities: map[DataNode]string{},
+		removed:  map[string]int64{},
+		version:  0,
+		mutex:    sync.RWMutex{},
+	}
+}
+
+// ForEach calls fn with each node and its set, it iterates over a copy so fn may modify the backend.
+func (b *BackendInMemory) ForEach(fn func(key DataNode, values []DataNode) error) error {
+	b.mutex.RLock()
+	links := b.links.Copy()
+	b.mutex.RUnlock()
+
+	for key := range links {
+		if err := fn(key, links.Get(key)); err != nil {
+			return err
+		}
 	}
+
+	return nil
+}
+
+func (b *BackendInMemory) Metadata() (Metadata, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	checkpoints := make(map[string]Checkpoint, len(b.metadata.Checkpoints))
+	for source, checkpoint := range b.metadata.Checkpoints {
+		checkpoints[source] = checkpoint
+	}
+
+	return Metadata{
+		Scans:       append([]ScanRecord{}, b.metadata.Scans...),
+		Checkpoints: checkpoints,
+		Compacted:   b.metadata.Compacted,
+	}, nil
+}
+
+func (b *BackendInMemory) SetMetadata(metadata Metadata) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.metadata = metadata
+
+	return nil
+}
+
+func (b *BackendInMemory) Changes() ([]Change, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	changes := make([]Change, 0, len(b.touched)+len(b.removed))
+	for node, version := range b.touched {
+		changes = append(changes, Change{Node: node, Version: version, Removed: ""})
+	}
+
+	for uuid, version := range b.removed {
+		changes = append(changes, Change{Node: DataNode{Key: "", Data: nil}, Version: version, Removed: uuid})
+	}
+
+	return changes, nil
+}
+
+func (b *BackendInMemory) Untouch(changes ...Change) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, change := range changes {
+		if change.Removed != "" {
+			if b.removed[change.Removed] == change.Version {
+				delete(b.removed, change.Removed)
+			}
+		} else if b.touched[change.Node] == change.Version {
+			delete(b.touched, change.Node)
+		}
+	}
+
+	return nil
+}
+
+func (b *BackendInMemory) EntityOf(node DataNode) (string, bool, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	uuid, found := b.entities[node]
+
+	return uuid, found, nil
+}
+
+func (b *BackendInMemory) SetEntity(uuid string, nodes ...DataNode) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, node := range nodes {
+		b.entities[node] = uuid
+	}
+
+	return nil
 }
 
 func (b *BackendInMemory) Close() error {
@@ -46,11 +219,17 @@ func (b *BackendInMemory) Close() error {
 }
 
 func (b *BackendInMemory) Next() (DataNode, bool, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
 	key, present := b.links.RandomKey()
 
 	return key, present, nil
 }
 
 func (b *BackendInMemory) PullAll(node DataNode) ([]DataNode, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
 	return b.links.Delete(node), nil
 }
@@ -0,0 +1,33 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo_test
+
+import (
+	"testing"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cgi-fr/silo/pkg/silo/silotest"
+)
+
+func TestBackendInMemory(t *testing.T) {
+	t.Parallel()
+
+	silotest.TestBackend(t, func(_ *testing.T) silo.Backend {
+		return silo.NewBackendInMemory()
+	})
+}
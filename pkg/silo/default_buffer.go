@@ -0,0 +1,83 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+// EntityBufferInMemory holds the nodes of an entity and the nodes left to visit in memory.
+type EntityBufferInMemory struct {
+	added    map[DataNode]bool
+	nodes    []DataNode
+	frontier []DataNode
+}
+
+func NewEntityBufferInMemory() *EntityBufferInMemory {
+	return &EntityBufferInMemory{
+		added:    make(map[DataNode]bool, defaultEntitySize),
+		nodes:    make([]DataNode, 0, defaultEntitySize),
+		frontier: []DataNode{},
+	}
+}
+
+func (b *EntityBufferInMemory) Add(node DataNode) (bool, error) {
+	if b.added[node] {
+		return false, nil
+	}
+
+	b.added[node] = true
+	b.nodes = append(b.nodes, node)
+
+	return true, nil
+}
+
+func (b *EntityBufferInMemory) ForEach(fn func(node DataNode) error) error {
+	for _, node := range b.nodes {
+		if err := fn(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *EntityBufferInMemory) Push(node DataNode) error {
+	b.frontier = append(b.frontier, node)
+
+	return nil
+}
+
+func (b *EntityBufferInMemory) Pop() (DataNode, bool, error) {
+	if len(b.frontier) == 0 {
+		return DataNode{Key: "", Data: nil}, false, nil
+	}
+
+	node := b.frontier[len(b.frontier)-1]
+	b.frontier = b.frontier[:len(b.frontier)-1]
+
+	return node, true, nil
+}
+
+func (b *EntityBufferInMemory) Reset() error {
+	b.added = make(map[DataNode]bool, defaultEntitySize)
+	b.nodes = make([]DataNode, 0, defaultEntitySize)
+	b.frontier = b.frontier[:0]
+
+	return nil
+}
+
+func (b *EntityBufferInMemory) Close() error {
+	return nil
+}
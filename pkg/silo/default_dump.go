@@ -23,8 +23,8 @@ func NewDumpToStdout() *DumpToStdout {
 	return &DumpToStdout{}
 }
 
-func (d *DumpToStdout) Write(node DataNode, uuid string) error {
-	println(uuid, node.String())
+func (d *DumpToStdout) Write(node DataNode, entity EntitySummary) error {
+	println(entity.UUID, node.String(), entity.Status)
 
 	return nil
 }
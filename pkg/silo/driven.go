@@ -22,20 +22,76 @@ type DataRowReader interface {
 	Close() error
 }
 
+// DataRowSkipper is implemented by readers able to skip rows faster than reading them, it returns the number
+// of skipped rows which is lower than count if the input ends before.
+type DataRowSkipper interface {
+	SkipDataRows(count int) (int, error)
+}
+
+// Backend stores the links of the silo, implementations used by concurrent scans must be safe for concurrent use.
 type Backend interface {
 	Store(key DataNode, value DataNode) error
+	Get(key DataNode) ([]DataNode, error)
+	// Remove value from the set of key, key is deleted when its set becomes empty.
+	Remove(key DataNode, value DataNode) error
+	Delete(key DataNode) error
 	Snapshot() Snapshot
 	Close() error
 }
 
+// MetadataBackend is implemented by backends able to persist the metadata of the silo, it is updated on each scan.
+type MetadataBackend interface {
+	Metadata() (Metadata, error)
+	SetMetadata(metadata Metadata) error
+}
+
+// Change records that a node was touched by a store, a remove or a delete, Version identifies its last change.
+// A change with Removed records instead that a node of the entity with this id was deleted, its Node is empty.
+type Change struct {
+	Node    DataNode
+	Version int64
+	Removed string
+}
+
+// ChangeTracker is implemented by backends that track the nodes touched since they were last dumped, and the id of
+// the entity each node belonged to in that dump.
+type ChangeTracker interface {
+	Changes() ([]Change, error)
+	// Untouch forgets the given changes, a node touched again since its change was read stays touched.
+	Untouch(changes ...Change) error
+	EntityOf(node DataNode) (string, bool, error)
+	SetEntity(uuid string, nodes ...DataNode) error
+}
+
+// Snapshot is a consistent view of the links, used by a single dump.
 type Snapshot interface {
 	Next() (DataNode, bool, error)
 	PullAll(node DataNode) ([]DataNode, error)
 	Close() error
 }
 
+// EntityBuffer holds the nodes of the entity being dumped and the nodes left to visit, it is reset for each entity.
+type EntityBuffer interface {
+	// Add records node in the entity, it returns false if node was already recorded.
+	Add(node DataNode) (bool, error)
+	// ForEach calls fn with the nodes recorded in the entity, in the order they were recorded.
+	ForEach(fn func(node DataNode) error) error
+	// Push adds a node left to visit, Pop returns the last node pushed and not popped yet.
+	Push(node DataNode) error
+	Pop() (DataNode, bool, error)
+	Reset() error
+	Close() error
+}
+
+// BufferedSnapshot is implemented by snapshots that provide the buffer of the entities they dump, e.g. to bound the
+// memory used by a large entity. Other snapshots are dumped with a buffer held in memory.
+type BufferedSnapshot interface {
+	Snapshot
+	Buffer() EntityBuffer
+}
+
 type DumpWriter interface {
-	Write(node DataNode, uuid string) error
+	Write(node DataNode, entity EntitySummary) error
 	Close() error
 }
 
@@ -45,5 +101,5 @@ type ScanObserver interface {
 }
 
 type DumpObserver interface {
-	Entity(status Status, counts map[string]int)
+	Entity(entity EntitySummary)
 }
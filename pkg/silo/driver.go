@@ -18,17 +18,28 @@
 package silo
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Driver is safe for concurrent use if its backend is : several scans can run at the same time, and a dump
+// reads a snapshot of the backend so it does not see links stored by scans started after it.
 type Driver struct {
 	*config
-	backend Backend
-	writer  DumpWriter
+	membership *membership
+	backend    Backend
+	writer     DumpWriter
+	sequence   int64
+	mutex      sync.Mutex // serializes updates of the metadata
+	changes    sync.Mutex // serializes dumps of changes
 }
 
 func NewDriver(backend Backend, writer DumpWriter, options ...Option) *Driver {
@@ -49,19 +60,42 @@ func NewDriver(backend Backend, writer DumpWriter, options ...Option) *Driver {
 		panic(errs)
 	}
 
+	membership := config.buildMembership()
+	config.includeConstrained()
+
 	return &Driver{
-		backend: backend,
-		writer:  writer,
-		config:  config,
+		membership: membership,
+		backend:    backend,
+		writer:     writer,
+		config:     config,
+		sequence:   0,
+		mutex:      sync.Mutex{},
+		changes:    sync.Mutex{},
 	}
 }
 
 func (d *Driver) Dump(observers ...DumpObserver) error {
+	return d.DumpContext(context.Background(), observers...)
+}
+
+// DumpContext dumps entities until ctx is done, it stops between two entities so the last entity is
+// always fully written, and returns an error wrapping ErrInterrupted and the context error.
+func (d *Driver) DumpContext(ctx context.Context, observers ...DumpObserver) error {
 	snapshot := d.backend.Snapshot()
 
 	defer snapshot.Close()
 
+	buffer := d.buffer(snapshot)
+
+	defer buffer.Close()
+
 	for count := 0; ; count++ {
+		if err := ctx.Err(); err != nil {
+			log.Warn().Int("entities", count).Msg("dump interrupted")
+
+			return fmt.Errorf("%w: %w", ErrInterrupted, err)
+		}
+
 		entryNode, hasNext, err := snapshot.Next()
 		if err != nil {
 			return fmt.Errorf("%w", err)
@@ -71,52 +105,151 @@ func (d *Driver) Dump(observers ...DumpObserver) error {
 			break
 		}
 
-		entity := NewEntity(d.config.includeList, entryNode)
+		entity := newEntity(d.config.includeList, d.membership)
 
-		if err := d.write(entryNode, entity.UUID()); err != nil {
+		connectedNodes, err := snapshot.PullAll(entryNode)
+		if err != nil {
 			return fmt.Errorf("%w", err)
 		}
 
-		if err := d.dump(snapshot, entryNode, entity); err != nil {
-			return fmt.Errorf("%w", err)
+		if err := d.dump(snapshot, buffer, entryNode, connectedNodes, entity); err != nil {
+			return err
 		}
 
-		status, counts := entity.Finalize()
-
-		for _, observer := range observers {
-			if observer != nil {
-				observer.Entity(status, counts)
-			}
+		if _, err := d.emit(entity, buffer, nil, observers); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (d *Driver) dump(snapshot Snapshot, node DataNode, entity Entity) error {
-	connectedNodes, err := snapshot.PullAll(node)
-	if err != nil {
+// emit writes and observes the entity if its status is selected, it returns false if the entity is filtered out.
+func (d *Driver) emit(entity Entity, buffer EntityBuffer, replaces []string, observers []DumpObserver) (bool, error) {
+	status, counts, violations := entity.Finalize()
+
+	// entity is buffered until its status is known, filtered out entities are not written nor observed
+	if _, selected := d.config.statuses[status]; !selected && len(d.config.statuses) > 0 {
+		return false, nil
+	}
+
+	summary := EntitySummary{
+		UUID:       entity.UUID(),
+		Status:     status,
+		Counts:     counts,
+		Violations: violations,
+		Attributes: entity.Attributes(d.config.policies),
+		Replaces:   replaces,
+		Removed:    false,
+	}
+
+	if err := buffer.ForEach(func(node DataNode) error { return d.write(node, summary) }); err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	for _, observer := range observers {
+		if observer != nil {
+			observer.Entity(summary)
+		}
+	}
+
+	return true, nil
+}
+
+// buffer returns the buffer of the entities dumped from snapshot, held in memory unless the snapshot provides one.
+func (d *Driver) buffer(snapshot Snapshot) EntityBuffer { //nolint:ireturn
+	if buffered, ok := snapshot.(BufferedSnapshot); ok {
+		return buffered.Buffer()
+	}
+
+	return NewEntityBufferInMemory()
+}
+
+// dump resets buffer with node, already pulled from snapshot, and expands entity from the nodes connected to it.
+func (d *Driver) dump(
+	snapshot Snapshot, buffer EntityBuffer, node DataNode, connectedNodes []DataNode, entity Entity,
+) error {
+	if err := buffer.Reset(); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	for _, connectedNode := range connectedNodes {
-		if entity.Append(connectedNode) {
-			if err := d.write(connectedNode, entity.UUID()); err != nil {
-				return fmt.Errorf("%w", err)
-			}
+	if _, err := buffer.Add(node); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	entity.count(node)
+
+	return d.expand(snapshot, buffer, connectedNodes, entity)
+}
 
-			if err := d.dump(snapshot, connectedNode, entity); err != nil {
-				return fmt.Errorf("%w", err)
+// expand adds to the entity the given nodes connected to it, and their own connected nodes. The frontier is the
+// stack of the buffer, so a long chain of nodes does not grow the call stack, and nodes are visited in depth-first
+// order.
+func (d *Driver) expand(snapshot Snapshot, buffer EntityBuffer, connectedNodes []DataNode, entity Entity) error {
+	if err := push(buffer, connectedNodes); err != nil {
+		return err
+	}
+
+	for {
+		connectedNode, ok, err := buffer.Pop()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		if connectedNode.IsSoft() {
+			if !d.config.softLinks {
+				continue
 			}
+
+			connectedNode = connectedNode.Hard()
+		}
+
+		if connectedNode.IsAttribute() {
+			entity.Append(connectedNode)
+
+			continue
+		}
+
+		added, err := buffer.Add(connectedNode)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if !added {
+			continue
+		}
+
+		entity.count(connectedNode)
+
+		next, err := snapshot.PullAll(connectedNode)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err := push(buffer, next); err != nil {
+			return err
+		}
+	}
+}
+
+// push adds nodes to the frontier of buffer in reverse order, so they are popped in their own order.
+func push(buffer EntityBuffer, nodes []DataNode) error {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if err := buffer.Push(nodes[i]); err != nil {
+			return fmt.Errorf("%w", err)
 		}
 	}
 
 	return nil
 }
 
-func (d *Driver) write(node DataNode, uuid string) error {
+func (d *Driver) write(node DataNode, entity EntitySummary) error {
 	if _, included := d.config.include[node.Key]; included || len(d.config.include) == 0 {
-		if err := d.writer.Write(node, uuid); err != nil {
+		if err := d.writer.Write(node, entity); err != nil {
 			return fmt.Errorf("%w", err)
 		}
 	}
@@ -125,9 +258,39 @@ func (d *Driver) write(node DataNode, uuid string) error {
 }
 
 func (d *Driver) Scan(input DataRowReader, observers ...ScanObserver) error {
+	return d.ScanContext(context.Background(), input, observers...)
+}
+
+// ScanContext scans input until ctx is done, it stops between two rows so a row is always fully ingested.
+// An interrupted scan is recorded in the metadata and returns an error wrapping ErrInterrupted
+// and the context error.
+func (d *Driver) ScanContext(ctx context.Context, input DataRowReader, observers ...ScanObserver) error {
 	defer input.Close()
 
+	index := newFuzzyIndex(d.config.fuzzy)
+	record := d.newScanRecord()
+
+	// new values are matched with the values of previous scans, the snapshot is taken only if needed
+	if len(d.config.fuzzy) > 0 {
+		if err := index.load(d.backend.Snapshot()); err != nil {
+			return err
+		}
+	}
+
+	checkpoint, err := d.startCheckpoint(input, &record)
+	if err != nil {
+		return err
+	}
+
 	for {
+		if ctx.Err() != nil {
+			record.Interrupted = true
+
+			log.Warn().Int("rows", record.Rows).Msg("scan interrupted")
+
+			break
+		}
+
 		datarow, err := input.ReadDataRow()
 		if err != nil && !errors.Is(err, io.EOF) {
 			return fmt.Errorf("%w: %w", ErrReadingNextInput, err)
@@ -144,6 +307,123 @@ func (d *Driver) Scan(input DataRowReader, observers ...ScanObserver) error {
 		if err := d.ingest(datarow, nodes, links, observers...); err != nil {
 			return err
 		}
+
+		// the sequence of a row only depends on its position in the source, so a row ingested again is idempotent
+		if err := d.ingestAttributes(datarow, nodes, checkpoint.Sequence+int64(checkpoint.Rows)); err != nil {
+			return err
+		}
+
+		index.add(nodes)
+
+		record.Rows++
+		record.Links += len(links)
+
+		if err := d.nextCheckpoint(&checkpoint); err != nil {
+			return err
+		}
+	}
+
+	if err := d.ingestSoftLinks(index.links()); err != nil {
+		return err
+	}
+
+	d.reserveSequence(checkpoint.Sequence + int64(checkpoint.Rows))
+
+	checkpoint.Time = time.Now()
+	checkpoint.Complete = !record.Interrupted
+
+	if err := d.recordScan(record, checkpoint); err != nil {
+		return err
+	}
+
+	if record.Interrupted {
+		return fmt.Errorf("%w: %w", ErrInterrupted, ctx.Err())
+	}
+
+	return nil
+}
+
+func (d *Driver) newScanRecord() ScanRecord {
+	record := ScanRecord{
+		Time:       time.Now(),
+		Source:     d.config.source,
+		Version:    d.config.version,
+		Rows:       0,
+		Links:      0,
+		Include:    d.config.includeList,
+		Aliases:    d.config.aliases,
+		Composites: make(map[string][]string, len(d.config.composites)),
+		Attributes: make([]string, 0, len(d.config.attributes)),
+		Fuzzy:      make([]string, 0, len(d.config.fuzzy)),
+	}
+
+	for _, composite := range d.config.composites {
+		record.Composites[composite.key] = composite.parts
+	}
+
+	for key := range d.config.attributes {
+		record.Attributes = append(record.Attributes, key)
+	}
+
+	for key := range d.config.fuzzy {
+		record.Fuzzy = append(record.Fuzzy, key)
+	}
+
+	sort.Strings(record.Attributes)
+	sort.Strings(record.Fuzzy)
+
+	return record
+}
+
+// recordScan appends the scan record and saves the checkpoint of its source in the metadata of the silo.
+func (d *Driver) recordScan(record ScanRecord, checkpoint Checkpoint) error {
+	return d.updateMetadata(func(metadata *Metadata) {
+		if fields := metadata.InconsistentAliases(record); len(fields) > 0 {
+			log.Warn().Strs("fields", fields).Msg("aliases are inconsistent with a previous scan of this silo")
+		}
+
+		metadata.Scans = append(metadata.Scans, record)
+		metadata.setCheckpoint(record.Source, checkpoint)
+	})
+}
+
+// updateMetadata applies update to the metadata of the silo, if the backend supports it.
+func (d *Driver) updateMetadata(update func(metadata *Metadata)) error {
+	backend, ok := d.backend.(MetadataBackend)
+	if !ok {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPersistingData, err)
+	}
+
+	update(&metadata)
+
+	if err := backend.SetMetadata(metadata); err != nil {
+		return fmt.Errorf("%w: %w", ErrPersistingData, err)
+	}
+
+	return nil
+}
+
+func (d *Driver) ingestSoftLinks(links []DataLink) error {
+	for _, link := range links {
+		if err := d.backend.Store(link.E1, link.E2.Soft()); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+
+		if err := d.backend.Store(link.E2, link.E1.Soft()); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+	}
+
+	if len(links) > 0 {
+		log.Info().Int("soft-links", len(links)).Msg("fuzzy matching done")
 	}
 
 	return nil
@@ -178,11 +458,72 @@ func (d *Driver) ingest(datarow DataRow, nodes []DataNode, links []DataLink, obs
 	return nil
 }
 
+func (d *Driver) ingestAttributes(datarow DataRow, nodes []DataNode, sequence int64) error {
+	if len(d.config.attributes) == 0 || len(nodes) == 0 {
+		return nil
+	}
+
+	for key := range d.config.attributes {
+		attribute, ok := NewAttributeNode(key, datarow[key], sequence)
+		if !ok {
+			continue
+		}
+
+		for _, node := range nodes {
+			if err := d.backend.Store(node, attribute); err != nil {
+				return fmt.Errorf("%w: %w", ErrPersistingData, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// nextSequence returns a timestamp in nanoseconds, strictly greater than the previous one.
+func (d *Driver) nextSequence() int64 {
+	now := time.Now().UnixNano()
+
+	for {
+		last := atomic.LoadInt64(&d.sequence)
+		next := max(now, last+1)
+
+		if atomic.CompareAndSwapInt64(&d.sequence, last, next) {
+			return next
+		}
+	}
+}
+
+// reserveSequence makes sure the next sequence is strictly greater than last.
+func (d *Driver) reserveSequence(last int64) {
+	for {
+		current := atomic.LoadInt64(&d.sequence)
+		if current >= last || atomic.CompareAndSwapInt64(&d.sequence, current, last) {
+			return
+		}
+	}
+}
+
 func (d *Driver) scan(datarow DataRow) ([]DataNode, []DataLink) {
 	nodes := []DataNode{}
 	links := []DataLink{}
 
+	for _, composite := range d.config.composites {
+		if _, included := d.config.include[composite.key]; included || len(d.config.include) == 0 {
+			if value, complete := NewCompositeValue(datarow, composite.parts); complete {
+				nodes = append(nodes, DataNode{Key: composite.key, Data: value})
+			}
+		}
+	}
+
 	for key, value := range datarow {
+		if _, hidden := d.config.hidden[key]; hidden {
+			continue
+		}
+
+		if _, attribute := d.config.attributes[key]; attribute {
+			continue
+		}
+
 		if _, included := d.config.include[key]; value != nil && (included || len(d.config.include) == 0) {
 			if alias, exist := d.config.aliases[key]; exist {
 				key = alias
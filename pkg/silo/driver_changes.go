@@ -0,0 +1,247 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// DumpChanged dumps only the entities containing a node touched since it was last dumped by DumpChanged, each
+// entity lists the ids of the entities it replaces. An entity is forgotten as changed only once it is written or
+// filtered out, so an interrupted dump of changes can be started again. Nodes deleted from the silo are not dumped,
+// an entity left without any node is replaced by a removed entity. Changes are forgotten at once when the dump ends,
+// even on error, to sync the backend a single time.
+func (d *Driver) DumpChanged(ctx context.Context, observers ...DumpObserver) (err error) {
+	tracker, ok := d.backend.(ChangeTracker)
+	if !ok {
+		return ErrChangesNotTracked
+	}
+
+	d.changes.Lock()
+	defer d.changes.Unlock()
+
+	// changes are read before the snapshot, so a node touched during the dump is dumped again next time
+	changes, err := tracker.Changes()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	touched := make(map[DataNode]Change, len(changes))
+	for _, change := range changes {
+		touched[change.Node] = change
+	}
+
+	snapshot := d.backend.Snapshot()
+
+	defer snapshot.Close()
+
+	buffer := d.buffer(snapshot)
+
+	defer buffer.Close()
+
+	dumped := map[DataNode]bool{}
+	replaced := map[string]bool{}
+	removals := []Change{}
+	done := []Change{}
+
+	defer func() {
+		if len(done) == 0 {
+			return
+		}
+
+		if untouchErr := tracker.Untouch(done...); untouchErr != nil && err == nil {
+			err = fmt.Errorf("%w", untouchErr)
+		}
+	}()
+
+	for count, change := range changes {
+		if err := ctx.Err(); err != nil {
+			log.Warn().Int("changes", count).Msg("dump interrupted")
+
+			return fmt.Errorf("%w: %w", ErrInterrupted, err)
+		}
+
+		// removals are written last, once the entities still holding nodes replaced their previous ids
+		if change.Removed != "" {
+			removals = append(removals, change)
+
+			continue
+		}
+
+		if dumped[change.Node] {
+			continue
+		}
+
+		connectedNodes, err := snapshot.PullAll(change.Node)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if len(connectedNodes) == 0 {
+			// the node was deleted from the silo
+			done = append(done, change)
+
+			continue
+		}
+
+		changes, err := d.dumpChanged(
+			tracker, snapshot, buffer, change.Node, connectedNodes, touched, dumped, replaced, observers,
+		)
+		if err != nil {
+			return err
+		}
+
+		done = append(done, changes...)
+	}
+
+	for _, removal := range removals {
+		if !replaced[removal.Removed] {
+			if err := d.emitRemoval(removal.Removed, observers); err != nil {
+				return err
+			}
+
+			replaced[removal.Removed] = true
+		}
+
+		done = append(done, removal)
+	}
+
+	return nil
+}
+
+func (d *Driver) dumpChanged(
+	tracker ChangeTracker,
+	snapshot Snapshot,
+	buffer EntityBuffer,
+	node DataNode,
+	connectedNodes []DataNode,
+	touched map[DataNode]Change,
+	dumped map[DataNode]bool,
+	replaced map[string]bool,
+	observers []DumpObserver,
+) ([]Change, error) {
+	entity := newEntity(d.config.includeList, d.membership)
+
+	if err := d.dump(snapshot, buffer, node, connectedNodes, entity); err != nil {
+		return nil, err
+	}
+
+	// the nodes of a changed entity are held in memory to match them with the previous dump
+	nodes := []DataNode{}
+
+	if err := buffer.ForEach(func(node DataNode) error {
+		nodes = append(nodes, node)
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	replaces, err := previousEntities(tracker, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range replaces {
+		replaced[id] = true
+	}
+
+	changes := make([]Change, 0, len(nodes))
+
+	for _, node := range nodes {
+		dumped[node] = true
+
+		if change, ok := touched[node]; ok {
+			changes = append(changes, change)
+		}
+	}
+
+	written, err := d.emit(entity, buffer, replaces, observers)
+	if err != nil {
+		return nil, err
+	}
+
+	// an entity filtered out keeps the id of its last written version, so it is replaced when next written
+	if !written {
+		return changes, nil
+	}
+
+	if err := tracker.SetEntity(entity.UUID(), nodes...); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return changes, nil
+}
+
+// emitRemoval writes an entity without node replacing previous, all the nodes of this entity were deleted. It is
+// written whatever the selected statuses, so downstream systems always forget the entity.
+func (d *Driver) emitRemoval(previous string, observers []DumpObserver) error {
+	summary := EntitySummary{
+		UUID:       uuid.NewString(),
+		Status:     "",
+		Counts:     map[string]int{},
+		Violations: nil,
+		Attributes: nil,
+		Replaces:   []string{previous},
+		Removed:    true,
+	}
+
+	if err := d.writer.Write(DataNode{Key: "", Data: nil}, summary); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for _, observer := range observers {
+		if observer != nil {
+			observer.Entity(summary)
+		}
+	}
+
+	log.Info().Str("uuid", summary.UUID).Str("replaces", previous).Msg("entity removed")
+
+	return nil
+}
+
+// previousEntities returns the sorted ids of the entities that contained the nodes in the last dump of changes.
+func previousEntities(tracker ChangeTracker, nodes []DataNode) ([]string, error) {
+	uuids := map[string]bool{}
+
+	for _, node := range nodes {
+		id, found, err := tracker.EntityOf(node)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if found {
+			uuids[id] = true
+		}
+	}
+
+	result := make([]string, 0, len(uuids))
+	for id := range uuids {
+		result = append(result, id)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCheckpointInterval is the number of rows between two checkpoints.
+const DefaultCheckpointInterval = 10000
+
+// startCheckpoint returns the checkpoint of a new scan, when resuming it skips the rows of input already ingested
+// and reuses the sequence of the previous scan so attributes of rows ingested again are identical. A source whose
+// last scan is complete is scanned again from the beginning.
+func (d *Driver) startCheckpoint(input DataRowReader, record *ScanRecord) (Checkpoint, error) {
+	checkpoint := Checkpoint{Rows: 0, Sequence: 0, Time: time.Now(), Complete: false}
+
+	if d.config.resume {
+		previous, err := d.lastCheckpoint()
+		if err != nil {
+			return checkpoint, err
+		}
+
+		if previous.Complete {
+			log.Warn().Str("source", d.config.source).Msg("last scan of this source is complete, scan starts from the beginning")
+
+			previous = Checkpoint{Rows: 0, Sequence: 0, Time: time.Time{}, Complete: false}
+		}
+
+		skipped, err := skipDataRows(input, previous.Rows)
+		if err != nil {
+			return checkpoint, err
+		}
+
+		if skipped < previous.Rows {
+			log.Warn().Int("checkpoint", previous.Rows).Int("skipped", skipped).Msg("input is shorter than checkpoint")
+		}
+
+		log.Info().Str("source", d.config.source).Int("skipped", skipped).Msg("scan resumed")
+
+		record.Skipped = skipped
+		checkpoint.Rows = skipped
+		checkpoint.Sequence = previous.Sequence
+	}
+
+	if checkpoint.Sequence == 0 {
+		checkpoint.Sequence = d.nextSequence()
+	}
+
+	return checkpoint, nil
+}
+
+func (d *Driver) lastCheckpoint() (Checkpoint, error) {
+	checkpoint := Checkpoint{Rows: 0, Sequence: 0, Time: time.Time{}, Complete: false}
+
+	backend, ok := d.backend.(MetadataBackend)
+	if !ok {
+		log.Warn().Msg("backend does not store checkpoints, scan is not resumed")
+
+		return checkpoint, nil
+	}
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return checkpoint, fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+	}
+
+	if previous, ok := metadata.Checkpoint(d.config.source); ok {
+		return previous, nil
+	}
+
+	log.Warn().Str("source", d.config.source).Msg("no checkpoint for this source, scan starts from the beginning")
+
+	return checkpoint, nil
+}
+
+// nextCheckpoint counts an ingested row and saves the checkpoint at each interval.
+func (d *Driver) nextCheckpoint(checkpoint *Checkpoint) error {
+	checkpoint.Rows++
+
+	if d.config.checkpoint <= 0 || checkpoint.Rows%d.config.checkpoint != 0 {
+		return nil
+	}
+
+	checkpoint.Time = time.Now()
+
+	return d.updateMetadata(func(metadata *Metadata) {
+		metadata.setCheckpoint(d.config.source, *checkpoint)
+	})
+}
+
+func skipDataRows(input DataRowReader, count int) (int, error) {
+	if skipper, ok := input.(DataRowSkipper); ok {
+		skipped, err := skipper.SkipDataRows(count)
+		if err != nil {
+			return skipped, fmt.Errorf("%w: %w", ErrReadingNextInput, err)
+		}
+
+		return skipped, nil
+	}
+
+	for skipped := 0; skipped < count; skipped++ {
+		datarow, err := input.ReadDataRow()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return skipped, fmt.Errorf("%w: %w", ErrReadingNextInput, err)
+		}
+
+		if errors.Is(err, io.EOF) || datarow == nil {
+			return skipped, nil
+		}
+	}
+
+	return count, nil
+}
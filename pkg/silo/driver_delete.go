@@ -0,0 +1,263 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Delete removes nodes from the silo, with all their links in both directions.
+// A node that loses its last link is kept as an entity on its own.
+func (d *Driver) Delete(nodes ...DataNode) error {
+	for _, node := range nodes {
+		if err := d.delete(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteAll removes all nodes read from input, each key/value pair of a row is a node to delete. Composite keys
+// configured with WithComposite are combined from their columns like a scan does, these columns are then hidden.
+func (d *Driver) DeleteAll(input DataRowReader) error {
+	defer input.Close()
+
+	for {
+		datarow, err := input.ReadDataRow()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %w", ErrReadingNextInput, err)
+		}
+
+		if errors.Is(err, io.EOF) || datarow == nil {
+			break
+		}
+
+		for _, composite := range d.config.composites {
+			if value, complete := NewCompositeValue(datarow, composite.parts); complete {
+				if err := d.delete(DataNode{Key: composite.key, Data: value}); err != nil {
+					return err
+				}
+			}
+		}
+
+		for key, value := range datarow {
+			if _, hidden := d.config.hidden[key]; hidden || value == nil {
+				continue
+			}
+
+			if err := d.delete(DataNode{Key: key, Data: value}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) delete(node DataNode) error {
+	neighbours, err := d.backend.Get(node)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+	}
+
+	if err := d.backend.Delete(node); err != nil {
+		return fmt.Errorf("%w: %w", ErrPersistingData, err)
+	}
+
+	for _, neighbour := range neighbours {
+		if neighbour.IsAttribute() || neighbour == node {
+			continue
+		}
+
+		reverse := node
+		if neighbour.IsSoft() {
+			neighbour, reverse = neighbour.Hard(), node.Soft()
+		}
+
+		// the self reference is stored first, so the set of the neighbour is never empty and its entity is kept
+		if err := d.keepIsolated(neighbour, reverse); err != nil {
+			return err
+		}
+
+		if err := d.backend.Remove(neighbour, reverse); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+	}
+
+	log.Info().Stringer("node", node).Int("links", len(neighbours)).Msg("node deleted")
+
+	return nil
+}
+
+// keepIsolated adds a self reference to node if it has no link left but the excepted ones, so it is still dumped as
+// an entity.
+func (d *Driver) keepIsolated(node DataNode, except ...DataNode) error {
+	linked, err := d.linked(node, except...)
+	if err != nil || linked {
+		return err
+	}
+
+	if err := d.backend.Store(node, node); err != nil {
+		return fmt.Errorf("%w: %w", ErrPersistingData, err)
+	}
+
+	return nil
+}
+
+// linked returns true if node has at least one link but the excepted ones, self reference included.
+func (d *Driver) linked(node DataNode, except ...DataNode) (bool, error) {
+	neighbours, err := d.backend.Get(node)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+	}
+
+	for _, neighbour := range neighbours {
+		if !neighbour.IsAttribute() && !slices.Contains(except, neighbour) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Retract removes the links contributed by rows read from input, rows are analyzed with the same options as Scan.
+// Links are stored as sets, so a link that was also contributed by another row is removed as well.
+func (d *Driver) Retract(input DataRowReader) error {
+	defer input.Close()
+
+	compacted, err := d.compacted()
+	if err != nil {
+		return err
+	}
+
+	for {
+		datarow, err := input.ReadDataRow()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %w", ErrReadingNextInput, err)
+		}
+
+		if errors.Is(err, io.EOF) || datarow == nil {
+			break
+		}
+
+		nodes, links := d.scan(datarow)
+
+		if err := d.retract(datarow, nodes, links, compacted); err != nil {
+			return err
+		}
+
+		log.Info().Int("links", len(links)).Interface("row", datarow).Msg("datarow retracted")
+	}
+
+	return nil
+}
+
+// compacted returns true if the self references of the silo were dropped by a compaction.
+func (d *Driver) compacted() (bool, error) {
+	backend, ok := d.backend.(MetadataBackend)
+	if !ok {
+		return false, nil
+	}
+
+	metadata, err := backend.Metadata()
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+	}
+
+	return metadata.Compacted, nil
+}
+
+// retract removes the links of a row, a node left without link is deleted. In a compacted silo, a node that lost its
+// last link may have been contributed on its own by another row, it is kept with a self reference.
+func (d *Driver) retract(datarow DataRow, nodes []DataNode, links []DataLink, compacted bool) error {
+	for _, link := range links {
+		if err := d.backend.Remove(link.E1, link.E2); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+
+		if err := d.backend.Remove(link.E2, link.E1); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+	}
+
+	if len(links) == 0 && len(nodes) > 0 {
+		if err := d.backend.Remove(nodes[0], nodes[0]); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+	}
+
+	for _, node := range nodes {
+		if err := d.retractAttributes(datarow, node); err != nil {
+			return err
+		}
+
+		if compacted && len(links) > 0 {
+			if err := d.keepIsolated(node); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// a node with attributes only is not part of any entity anymore
+		if linked, err := d.linked(node); err != nil {
+			return err
+		} else if !linked {
+			if err := d.backend.Delete(node); err != nil {
+				return fmt.Errorf("%w: %w", ErrPersistingData, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) retractAttributes(datarow DataRow, node DataNode) error {
+	if len(d.config.attributes) == 0 {
+		return nil
+	}
+
+	neighbours, err := d.backend.Get(node)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+	}
+
+	for _, neighbour := range neighbours {
+		attribute, isAttribute := neighbour.Data.(AttributeValue)
+		if !isAttribute {
+			continue
+		}
+
+		key := neighbour.Key
+		if _, declared := d.config.attributes[key]; !declared || datarow[key] != attribute.Value {
+			continue
+		}
+
+		if err := d.backend.Remove(node, neighbour); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersistingData, err)
+		}
+	}
+
+	return nil
+}
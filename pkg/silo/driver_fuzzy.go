@@ -0,0 +1,147 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"fmt"
+	"strings"
+)
+
+type fuzzyMatcher struct {
+	blocking   func(value string) []string
+	similarity func(a, b string) float64
+	threshold  float64
+}
+
+// fuzzyIndex groups the string values seen during a scan by blocks, only values in the same block are compared.
+// Values already stored in the silo before the scan are indexed too, so they are matched with the new values.
+type fuzzyIndex struct {
+	matchers map[string]fuzzyMatcher
+	blocks   map[string]map[string]map[DataNode]string
+	stored   map[DataNode]bool
+}
+
+func newFuzzyIndex(matchers map[string]fuzzyMatcher) *fuzzyIndex {
+	return &fuzzyIndex{
+		matchers: matchers,
+		blocks:   make(map[string]map[string]map[DataNode]string, len(matchers)),
+		stored:   map[DataNode]bool{},
+	}
+}
+
+// load indexes the values of the fuzzy keys already stored in the silo, it closes the snapshot.
+func (idx *fuzzyIndex) load(snapshot Snapshot) error {
+	defer snapshot.Close()
+
+	for {
+		node, hasNext, err := snapshot.Next()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+		}
+
+		if !hasNext {
+			return nil
+		}
+
+		// the snapshot is a private view, pulling the node only moves it to the next one
+		if _, err := snapshot.PullAll(node); err != nil {
+			return fmt.Errorf("%w: %w", ErrReadingPersistedData, err)
+		}
+
+		if idx.insert(node) {
+			idx.stored[node] = true
+		}
+	}
+}
+
+func (idx *fuzzyIndex) add(nodes []DataNode) {
+	for _, node := range nodes {
+		if idx.insert(node) {
+			delete(idx.stored, node)
+		}
+	}
+}
+
+// insert adds node to its blocks, it returns false if node is not a string value of a fuzzy key.
+func (idx *fuzzyIndex) insert(node DataNode) bool {
+	matcher, exist := idx.matchers[node.Key]
+	if !exist {
+		return false
+	}
+
+	value, isString := node.Data.(string)
+	if !isString {
+		return false
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(value))
+
+	blocks, exist := idx.blocks[node.Key]
+	if !exist {
+		blocks = map[string]map[DataNode]string{}
+		idx.blocks[node.Key] = blocks
+	}
+
+	for _, block := range matcher.blocking(normalized) {
+		if _, exist := blocks[block]; !exist {
+			blocks[block] = map[DataNode]string{}
+		}
+
+		blocks[block][node] = normalized
+	}
+
+	return true
+}
+
+// links compares all values sharing a block and returns the pairs that are similar enough.
+// Pairs of values stored before the scan were already compared by a previous scan and are skipped.
+func (idx *fuzzyIndex) links() []DataLink {
+	found := map[DataLink]bool{}
+	links := []DataLink{}
+
+	for key, blocks := range idx.blocks {
+		matcher := idx.matchers[key]
+
+		for _, block := range blocks {
+			nodes := make([]DataNode, 0, len(block))
+			for node := range block {
+				nodes = append(nodes, node)
+			}
+
+			for i := 0; i < len(nodes); i++ {
+				for j := i + 1; j < len(nodes); j++ {
+					link := DataLink{E1: nodes[i], E2: nodes[j]}
+					if nodes[j].Data.(string) < nodes[i].Data.(string) { //nolint:forcetypeassert
+						link = DataLink{E1: nodes[j], E2: nodes[i]}
+					}
+
+					if found[link] || (idx.stored[nodes[i]] && idx.stored[nodes[j]]) {
+						continue
+					}
+
+					if matcher.similarity(block[nodes[i]], block[nodes[j]]) >= matcher.threshold {
+						found[link] = true
+						links = append(links, link)
+					}
+				}
+			}
+		}
+	}
+
+	return links
+}
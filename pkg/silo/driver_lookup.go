@@ -0,0 +1,77 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import "fmt"
+
+// Lookup returns the summary and the nodes of the entity that contains node, reading links directly
+// from the backend. It returns false if node is not stored in the backend.
+func (d *Driver) Lookup(node DataNode) (EntitySummary, []DataNode, bool, error) {
+	entity := newEntity(d.config.includeList, d.membership, node)
+	pending := []DataNode{node}
+	nodes := []DataNode{}
+	found := false
+
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		if _, included := d.config.include[current.Key]; included || len(d.config.include) == 0 {
+			nodes = append(nodes, current)
+		}
+
+		connectedNodes, err := d.backend.Get(current)
+		if err != nil {
+			return EntitySummary{}, nil, false, fmt.Errorf("%w", err) //nolint:exhaustruct
+		}
+
+		found = found || len(connectedNodes) > 0
+
+		for _, connectedNode := range connectedNodes {
+			if connectedNode.IsSoft() {
+				if !d.config.softLinks {
+					continue
+				}
+
+				connectedNode = connectedNode.Hard()
+			}
+
+			if entity.Append(connectedNode) {
+				pending = append(pending, connectedNode)
+			}
+		}
+	}
+
+	if !found {
+		return EntitySummary{}, nil, false, nil //nolint:exhaustruct
+	}
+
+	status, counts, violations := entity.Finalize()
+
+	summary := EntitySummary{
+		UUID:       entity.UUID(),
+		Status:     status,
+		Counts:     counts,
+		Violations: violations,
+		Attributes: entity.Attributes(d.config.policies),
+		Replaces:   nil,
+		Removed:    false,
+	}
+
+	return summary, nodes, true, nil
+}
@@ -18,9 +18,17 @@
 package silo_test
 
 import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sort"
+	"sync"
 	"testing"
 
+	"github.com/cgi-fr/silo/pkg/fuzzy"
 	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/cgi-fr/silo/pkg/silo/silotest"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,13 +42,38 @@ func TestNominal(t *testing.T) {
 	input := silo.NewDataRowReaderInMemory(rows)
 
 	backend := silo.NewBackendInMemory()
-	writer := silo.NewDumpToStdout()
-	driver := silo.NewDriver(backend, writer)
+	driver := silo.NewDriver(backend, nil)
 
 	err := driver.Scan(input)
 	require.NoError(t, err)
 
-	require.NoError(t, driver.Dump())
+	assert.Equal(t, [][]string{
+		{"ID1=number(1)", "ID2=string(1)", "ID3=number(1.1)", "ID4=string(00001)"},
+		{"ID1=number(2)", "ID2=string(2)", "ID3=number(2.2)", "ID4=string(00002)"},
+	}, silotest.Entities(t, backend))
+}
+
+// TestLongChain dumps an entity whose nodes form a chain deeper than the call stack allows, the test is not
+// parallel since the maximum stack size applies to the whole process.
+func TestLongChain(t *testing.T) { //nolint:paralleltest
+	const length = 20000
+
+	rows := make([]silo.DataRow, 0, 2*length)
+	for i := 0; i < length; i++ {
+		rows = append(rows, silo.DataRow{"ID1": i, "ID2": i}, silo.DataRow{"ID2": i, "ID1": i + 1})
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil)
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	entities := silotest.Entities(t, backend)
+
+	require.Len(t, entities, 1)
+	assert.Len(t, entities[0], 2*length+1)
 }
 
 func TestPartialNull(t *testing.T) {
@@ -53,13 +86,15 @@ func TestPartialNull(t *testing.T) {
 	input := silo.NewDataRowReaderInMemory(rows)
 
 	backend := silo.NewBackendInMemory()
-	writer := silo.NewDumpToStdout()
-	driver := silo.NewDriver(backend, writer)
+	driver := silo.NewDriver(backend, nil)
 
 	err := driver.Scan(input)
 	require.NoError(t, err)
 
-	require.NoError(t, driver.Dump())
+	assert.Equal(t, [][]string{
+		{"ID1=number(1)", "ID4=string(00001)"},
+		{"ID2=string(2)", "ID3=number(2)"},
+	}, silotest.Entities(t, backend))
 }
 
 func TestPartialMissing(t *testing.T) {
@@ -72,13 +107,15 @@ func TestPartialMissing(t *testing.T) {
 	input := silo.NewDataRowReaderInMemory(rows)
 
 	backend := silo.NewBackendInMemory()
-	writer := silo.NewDumpToStdout()
-	driver := silo.NewDriver(backend, writer)
+	driver := silo.NewDriver(backend, nil)
 
 	err := driver.Scan(input)
 	require.NoError(t, err)
 
-	require.NoError(t, driver.Dump())
+	assert.Equal(t, [][]string{
+		{"ID1=number(1)", "ID4=string(00001)"},
+		{"ID2=string(2)", "ID3=number(2)"},
+	}, silotest.Entities(t, backend))
 }
 
 func TestReconnect(t *testing.T) {
@@ -91,13 +128,15 @@ func TestReconnect(t *testing.T) {
 	input := silo.NewDataRowReaderInMemory(rows)
 
 	backend := silo.NewBackendInMemory()
-	writer := silo.NewDumpToStdout()
-	driver := silo.NewDriver(backend, writer)
+	driver := silo.NewDriver(backend, nil)
 
 	err := driver.Scan(input)
 	require.NoError(t, err)
 
-	require.NoError(t, driver.Dump())
+	assert.Equal(t, [][]string{
+		{"ID1=number(1)", "ID4=string(00001)"},
+		{"ID2=string(1)", "ID3=number(1.1)"},
+	}, silotest.Entities(t, backend))
 
 	rows = []silo.DataRow{
 		{"ID1": 1, "ID2": "1"},
@@ -107,5 +146,742 @@ func TestReconnect(t *testing.T) {
 	err = driver.Scan(input)
 	require.NoError(t, err)
 
+	assert.Equal(t, [][]string{
+		{"ID1=number(1)", "ID2=string(1)", "ID3=number(1.1)", "ID4=string(00001)"},
+	}, silotest.Entities(t, backend))
+}
+
+type dumpToMemory struct {
+	entities map[string][]silo.DataNode
+}
+
+func newDumpToMemory() *dumpToMemory {
+	return &dumpToMemory{entities: map[string][]silo.DataNode{}}
+}
+
+func (d *dumpToMemory) Write(node silo.DataNode, entity silo.EntitySummary) error {
+	d.entities[entity.UUID] = append(d.entities[entity.UUID], node)
+
+	return nil
+}
+
+func (d *dumpToMemory) Close() error {
+	return nil
+}
+
+func TestDumpStatusFilter(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID1": 1, "ID2": "1"},
+		{"ID1": 2, "ID2": "2"},
+		{"ID1": 2, "ID2": "3"},
+		{"ID1": 4},
+	}
+
+	backend := silo.NewBackendInMemory()
+	writer := newDumpToMemory()
+	driver := silo.NewDriver(backend, writer,
+		silo.WithKeys([]string{"ID1", "ID2"}),
+		silo.WithStatuses([]silo.Status{silo.StatusEntityInconsistent}))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
 	require.NoError(t, driver.Dump())
+
+	require.Len(t, writer.entities, 1)
+
+	for _, nodes := range writer.entities {
+		assert.ElementsMatch(t, []silo.DataNode{
+			{Key: "ID1", Data: 2},
+			{Key: "ID2", Data: "2"},
+			{Key: "ID2", Data: "3"},
+		}, nodes)
+	}
+}
+
+type observeInMemory struct {
+	entities []silo.EntitySummary
+}
+
+func (o *observeInMemory) Entity(entity silo.EntitySummary) {
+	o.entities = append(o.entities, entity)
+}
+
+func TestDumpRules(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID1": 1, "ID2": "1", "ID3": "1"},
+		{"ID1": 2, "ID3": "2"},
+		{"ID1": 3, "ID2": "3"},
+		{"ID1": 3, "ID2": "4"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, newDumpToMemory(),
+		silo.WithMandatoryKeys([]string{"ID1"}),
+		silo.WithOptionalKeys([]string{"ID3"}),
+		silo.WithCardinality("ID2", 0, silo.Unbounded),
+		silo.WithRule("gold", silo.Constraint{Key: "ID2", Cardinality: silo.Cardinality{Min: 1, Max: 1}}))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	observer := &observeInMemory{}
+	require.NoError(t, driver.Dump(observer))
+
+	require.Len(t, observer.entities, 3)
+
+	// ID2 is not included, but it is counted since it is constrained
+	counts := []int{}
+
+	for _, entity := range observer.entities {
+		counts = append(counts, entity.Counts["ID2"])
+
+		switch entity.Counts["ID2"] {
+		case 1:
+			assert.Equal(t, silo.Status("gold"), entity.Status)
+			assert.Empty(t, entity.Violations)
+		case 0:
+			assert.Equal(t, silo.StatusEntityComplete, entity.Status)
+			assert.Equal(t, []silo.Violation{
+				{Rule: "gold", Key: "ID2", Count: 0, Cardinality: silo.Cardinality{Min: 1, Max: 1}},
+			}, entity.Violations)
+		case 2:
+			assert.Equal(t, silo.StatusEntityComplete, entity.Status)
+			assert.Equal(t, []silo.Violation{
+				{Rule: "gold", Key: "ID2", Count: 2, Cardinality: silo.Cardinality{Min: 1, Max: 1}},
+			}, entity.Violations)
+		}
+	}
+
+	assert.ElementsMatch(t, []int{0, 1, 2}, counts)
+}
+
+func TestFuzzyMatching(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "NAME": "Jonathan Smith"},
+		{"ID": 2, "NAME": "Jonathon Smith"},
+		{"ID": 3, "NAME": "Jon Smith"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil,
+		silo.WithFuzzyMatching("NAME", fuzzy.Soundex, fuzzy.JaroWinkler, 0.9))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	writer := newDumpToMemory()
+	require.NoError(t, silo.NewDriver(backend, writer).Dump())
+
+	require.Len(t, writer.entities, 2)
+
+	for _, nodes := range writer.entities {
+		if len(nodes) == 2 {
+			assert.ElementsMatch(t, []silo.DataNode{{Key: "ID", Data: 3}, {Key: "NAME", Data: "Jon Smith"}}, nodes)
+		} else {
+			assert.ElementsMatch(t, []silo.DataNode{
+				{Key: "ID", Data: 1},
+				{Key: "NAME", Data: "Jonathan Smith"},
+				{Key: "ID", Data: 2},
+				{Key: "NAME", Data: "Jonathon Smith"},
+			}, nodes)
+		}
+	}
+
+	writer = newDumpToMemory()
+	require.NoError(t, silo.NewDriver(backend, writer, silo.WithSoftLinks(false)).Dump())
+
+	require.Len(t, writer.entities, 3)
+}
+
+func TestFuzzyMatchingAcrossScans(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+	option := silo.WithFuzzyMatching("NAME", fuzzy.Soundex, fuzzy.JaroWinkler, 0.9)
+
+	first := []silo.DataRow{{"ID": 1, "NAME": "Jonathan Smith"}}
+	require.NoError(t, silo.NewDriver(backend, nil, option).Scan(silo.NewDataRowReaderInMemory(first)))
+
+	second := []silo.DataRow{{"ID": 2, "NAME": "Jonathon Smith"}, {"ID": 3, "NAME": "Jon Smith"}}
+	require.NoError(t, silo.NewDriver(backend, nil, option).Scan(silo.NewDataRowReaderInMemory(second)))
+
+	assert.ElementsMatch(t, sortEntities([][]silo.DataNode{
+		{
+			{Key: "ID", Data: 1},
+			{Key: "NAME", Data: "Jonathan Smith"},
+			{Key: "ID", Data: 2},
+			{Key: "NAME", Data: "Jonathon Smith"},
+		},
+		{{Key: "ID", Data: 3}, {Key: "NAME", Data: "Jon Smith"}},
+	}), dumpEntities(t, backend))
+}
+
+func TestSoftLinkMarkedColumn(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "~REF": "A"},
+		{"ID": 2, "~REF": "A"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	expected := sortEntities([][]silo.DataNode{
+		{{Key: "ID", Data: 1}, {Key: "~REF", Data: "A"}, {Key: "ID", Data: 2}},
+	})
+
+	assert.ElementsMatch(t, expected, dumpEntities(t, backend), "a column name is never a soft link mark")
+	assert.ElementsMatch(t, expected, dumpEntities(t, backend, silo.WithSoftLinks(false)))
+}
+
+func TestComposite(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "BRANCH": "001", "ACCOUNT": "12345"},
+		{"ID": 2, "BRANCH": "002", "ACCOUNT": "12345"},
+		{"ID": 3, "BRANCH": "001", "ACCOUNT": nil},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil, silo.WithComposite("BRANCH_ACCOUNT", []string{"BRANCH", "ACCOUNT"}))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	assert.ElementsMatch(t, [][]silo.DataNode{
+		{{Key: "ID", Data: 1}, {Key: "BRANCH_ACCOUNT", Data: silo.CompositeValue(`{"ACCOUNT":"12345","BRANCH":"001"}`)}},
+		{{Key: "ID", Data: 2}, {Key: "BRANCH_ACCOUNT", Data: silo.CompositeValue(`{"ACCOUNT":"12345","BRANCH":"002"}`)}},
+		{{Key: "ID", Data: 3}},
+	}, dumpEntities(t, backend))
+}
+
+func sortEntities(entities [][]silo.DataNode) [][]silo.DataNode {
+	for _, nodes := range entities {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].String() > nodes[j].String() })
+	}
+
+	return entities
+}
+
+func TestAttributes(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "SEEN": "2024-03-01", "SOURCE": "A", "SCORE": 5.0},
+		{"ID": 1, "EMAIL": "john@domain.com", "SEEN": "2024-01-01", "SOURCE": "B", "SCORE": 12.0},
+		{"EMAIL": "john@domain.com", "SEEN": "2024-02-01", "SOURCE": "A", "SCORE": 7.0},
+	}
+
+	backend := silo.NewBackendInMemory()
+	writer := newDumpToMemory()
+	driver := silo.NewDriver(backend, writer,
+		silo.WithAttributes([]string{"SEEN", "SOURCE", "SCORE"}),
+		silo.WithMergePolicy("SEEN", silo.MergeLast),
+		silo.WithMergePolicy("SCORE", silo.MergeMax))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	observer := &observeInMemory{}
+	require.NoError(t, driver.Dump(observer))
+
+	require.Len(t, observer.entities, 1)
+	assert.Equal(t, map[string]any{
+		"SEEN":   "2024-02-01",
+		"SOURCE": []any{"A", "B"},
+		"SCORE":  12.0,
+	}, observer.entities[0].Attributes)
+
+	for _, nodes := range writer.entities {
+		assert.ElementsMatch(t, []silo.DataNode{{Key: "ID", Data: 1}, {Key: "EMAIL", Data: "john@domain.com"}}, nodes)
+	}
+
+	observer = &observeInMemory{}
+	require.NoError(t, silo.NewDriver(backend, writer, silo.WithMergePolicy("SEEN", silo.MergeFirst)).Dump(observer))
+	assert.Equal(t, "2024-03-01", observer.entities[0].Attributes["SEEN"])
+}
+
+func TestAttributeMarkedColumn(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "@timestamp": "2024-03-01T10:00:00Z", "SOURCE": "A"},
+		{"ID": 2, "@timestamp": "2024-03-01T10:00:00Z", "SOURCE": "B"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	require.NoError(t, silo.NewDriver(backend, nil, silo.WithAttributes([]string{"SOURCE"})).
+		Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	writer := newDumpToMemory()
+	observer := &observeInMemory{}
+	require.NoError(t, silo.NewDriver(backend, writer).Dump(observer))
+
+	require.Len(t, observer.entities, 1, "a column name is never an attribute mark")
+	assert.Equal(t, map[string]int{"ID": 2, "@timestamp": 1}, observer.entities[0].Counts)
+	assert.Equal(t, map[string]any{"SOURCE": []any{"A", "B"}}, observer.entities[0].Attributes)
+
+	for _, nodes := range writer.entities {
+		assert.Contains(t, nodes, silo.DataNode{Key: "@timestamp", Data: "2024-03-01T10:00:00Z"})
+	}
+}
+
+func dumpEntities(t *testing.T, backend silo.Backend, options ...silo.Option) [][]silo.DataNode {
+	t.Helper()
+
+	writer := newDumpToMemory()
+	require.NoError(t, silo.NewDriver(backend, writer, options...).Dump())
+
+	entities := [][]silo.DataNode{}
+	for _, nodes := range writer.entities {
+		entities = append(entities, nodes)
+	}
+
+	return sortEntities(entities)
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "EMAIL": "john@domain.com", "PHONE": "0601"},
+		{"ID": 2, "EMAIL": "jane@domain.com"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil)
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+	require.NoError(t, driver.Delete(silo.DataNode{Key: "EMAIL", Data: "john@domain.com"}))
+	require.NoError(t, driver.DeleteAll(silo.NewDataRowReaderInMemory([]silo.DataRow{{"ID": 2}})))
+
+	assert.ElementsMatch(t, [][]silo.DataNode{
+		{{Key: "PHONE", Data: "0601"}, {Key: "ID", Data: 1}},
+		{{Key: "EMAIL", Data: "jane@domain.com"}},
+	}, dumpEntities(t, backend))
+}
+
+func TestDeleteComposite(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "BRANCH": "001", "ACCOUNT": "12345"},
+		{"ID": 2, "BRANCH": "002", "ACCOUNT": "6"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil, silo.WithComposite("BRANCH_ACCOUNT", []string{"BRANCH", "ACCOUNT"}))
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+	require.NoError(t, driver.DeleteAll(silo.NewDataRowReaderInMemory([]silo.DataRow{
+		{"ACCOUNT": "12345", "BRANCH": "001"},
+	})))
+
+	composite, _ := silo.NewCompositeValue(rows[1], []string{"BRANCH", "ACCOUNT"})
+
+	assert.ElementsMatch(t, [][]silo.DataNode{
+		{{Key: "ID", Data: 1}},
+		{{Key: "ID", Data: 2}, {Key: "BRANCH_ACCOUNT", Data: composite}},
+	}, dumpEntities(t, backend))
+}
+
+func TestRetract(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "EMAIL": "john@domain.com"},
+		{"ID": 1, "PHONE": "0601"},
+		{"ID": 2},
+	}
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil)
+
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+	require.NoError(t, driver.Retract(silo.NewDataRowReaderInMemory([]silo.DataRow{
+		{"ID": 1, "EMAIL": "john@domain.com"},
+		{"ID": 2},
+	})))
+
+	assert.ElementsMatch(t, [][]silo.DataNode{
+		{{Key: "PHONE", Data: "0601"}, {Key: "ID", Data: 1}},
+	}, dumpEntities(t, backend))
+}
+
+func TestScanMetadata(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+
+	rows := []silo.DataRow{
+		{"ID": "1", "EMAIL": "a"},
+		{"ID": "2", "EMAIL": "b", "PHONE": "0601"},
+	}
+
+	driver := silo.NewDriver(backend, nil, silo.WithSource("clients"), silo.WithVersion("1.0.0"))
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	driver = silo.NewDriver(backend, nil, silo.WithAliases(map[string]string{"ID": "CLIENT"}))
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows[:1])))
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+	require.Len(t, metadata.Scans, 2)
+	assert.Equal(t, "clients", metadata.Scans[0].Source)
+	assert.Equal(t, "1.0.0", metadata.Scans[0].Version)
+	assert.Equal(t, 2, metadata.Scans[0].Rows)
+	assert.Equal(t, 4, metadata.Scans[0].Links)
+
+	last, ok := metadata.LastScan()
+
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"ID": "CLIENT"}, last.Aliases)
+
+	previous := silo.Metadata{Scans: metadata.Scans[:1]}
+
+	assert.Equal(t, []string{"ID"}, previous.InconsistentAliases(last))
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+
+	rows := []silo.DataRow{
+		{"ID": "1", "EMAIL": "a"},
+		{"ID": "1", "PHONE": "0601"},
+		{"ID": "2", "EMAIL": "b"},
+	}
+
+	driver := silo.NewDriver(backend, nil, silo.WithKeys([]string{"ID", "EMAIL"}))
+	require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	driver = silo.NewDriver(backend, nil, silo.WithKeys([]string{"ID", "EMAIL", "PHONE"}))
+
+	entity, nodes, found, err := driver.Lookup(silo.DataNode{Key: "EMAIL", Data: "a"})
+
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, silo.StatusEntityConsistent, entity.Status)
+	assert.ElementsMatch(t, []silo.DataNode{{Key: "EMAIL", Data: "a"}, {Key: "ID", Data: "1"}}, nodes)
+
+	_, _, found, err = driver.Lookup(silo.DataNode{Key: "EMAIL", Data: "c"})
+
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestConcurrentScan(t *testing.T) {
+	t.Parallel()
+
+	const workers, rowsPerWorker = 8, 50
+
+	backend := silo.NewBackendInMemory()
+	driver := silo.NewDriver(backend, nil)
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < workers; worker++ {
+		rows := make([]silo.DataRow, 0, rowsPerWorker)
+		for row := 0; row < rowsPerWorker; row++ {
+			rows = append(rows, silo.DataRow{"ID": worker*rowsPerWorker + row, "GROUP": worker})
+		}
+
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			assert.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+		}()
+	}
+
+	// dumps run during scans, each reading a consistent snapshot
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			assert.NoError(t, silo.NewDriver(backend, newDumpToMemory()).Dump())
+		}()
+	}
+
+	wait.Wait()
+
+	entities := dumpEntities(t, backend)
+
+	assert.Len(t, entities, workers)
+
+	for _, entity := range entities {
+		assert.Len(t, entity, rowsPerWorker+1)
+	}
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+	assert.Len(t, metadata.Scans, workers)
+}
+
+// cancelingReader cancels the scan after a given number of rows.
+type cancelingReader struct {
+	silo.DataRowReader
+	cancel context.CancelFunc
+	after  int
+}
+
+func (r *cancelingReader) ReadDataRow() (silo.DataRow, error) {
+	if r.after--; r.after == 0 {
+		r.cancel()
+	}
+
+	return r.DataRowReader.ReadDataRow() //nolint:wrapcheck
+}
+
+func TestScanContext(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{{"ID": "1"}, {"ID": "2"}, {"ID": "3"}, {"ID": "4"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := silo.NewBackendInMemory()
+	reader := &cancelingReader{DataRowReader: silo.NewDataRowReaderInMemory(rows), cancel: cancel, after: 2}
+
+	err := silo.NewDriver(backend, nil).ScanContext(ctx, reader)
+
+	require.ErrorIs(t, err, silo.ErrInterrupted)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, dumpEntities(t, backend), 2)
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+	require.Len(t, metadata.Scans, 1)
+	assert.True(t, metadata.Scans[0].Interrupted)
+	assert.Equal(t, 2, metadata.Scans[0].Rows)
+}
+
+type cancelingObserver struct {
+	cancel context.CancelFunc
+}
+
+func (o cancelingObserver) Entity(_ silo.EntitySummary) {
+	o.cancel()
+}
+
+func TestDumpContext(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{{"ID": "1", "EMAIL": "a"}, {"ID": "2", "EMAIL": "b"}, {"ID": "3", "EMAIL": "c"}}
+
+	backend := silo.NewBackendInMemory()
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	writer := newDumpToMemory()
+	err := silo.NewDriver(backend, writer).DumpContext(ctx, cancelingObserver{cancel: cancel})
+
+	require.ErrorIs(t, err, silo.ErrInterrupted)
+	require.Len(t, writer.entities, 1)
+
+	for _, nodes := range writer.entities {
+		assert.Len(t, nodes, 2)
+	}
+}
+
+var errCrash = errors.New("crash")
+
+// crashingReader fails after a given number of rows.
+type crashingReader struct {
+	silo.DataRowReader
+	after int
+}
+
+func (r *crashingReader) ReadDataRow() (silo.DataRow, error) {
+	if r.after--; r.after < 0 {
+		return nil, errCrash
+	}
+
+	return r.DataRowReader.ReadDataRow() //nolint:wrapcheck
+}
+
+func TestResume(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID": "1", "EMAIL": "a", "SEEN": "2024-01"},
+		{"ID": "2", "EMAIL": "b", "SEEN": "2024-02"},
+		{"ID": "3", "EMAIL": "c", "SEEN": "2024-03"},
+		{"ID": "4", "EMAIL": "d", "SEEN": "2024-04"},
+		{"ID": "5", "EMAIL": "e", "SEEN": "2024-05"},
+	}
+
+	backend := silo.NewBackendInMemory()
+	options := []silo.Option{silo.WithSource("clients"), silo.WithCheckpoint(2), silo.WithAttributes([]string{"SEEN"})}
+
+	// the third row is ingested but the crash happens before the next checkpoint
+	reader := &crashingReader{DataRowReader: silo.NewDataRowReaderInMemory(rows), after: 3}
+	require.ErrorIs(t, silo.NewDriver(backend, nil, options...).Scan(reader), errCrash)
+
+	metadata, err := backend.Metadata()
+
+	require.NoError(t, err)
+
+	checkpoint, ok := metadata.Checkpoint("clients")
+
+	require.True(t, ok)
+	assert.Equal(t, 2, checkpoint.Rows)
+	assert.False(t, checkpoint.Complete)
+
+	options = append(options, silo.WithResume(true))
+	require.NoError(t, silo.NewDriver(backend, nil, options...).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	metadata, err = backend.Metadata()
+
+	require.NoError(t, err)
+
+	last, _ := metadata.LastScan()
+
+	assert.Equal(t, 2, last.Skipped)
+	assert.Equal(t, 3, last.Rows)
+
+	checkpoint, _ = metadata.Checkpoint("clients")
+
+	assert.Equal(t, 5, checkpoint.Rows)
+	assert.True(t, checkpoint.Complete)
+
+	// the row ingested twice has a single attribute value
+	nodes, err := backend.Get(silo.DataNode{Key: "ID", Data: "3"})
+
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Len(t, dumpEntities(t, backend), 5)
+
+	// the last scan of the source is complete, another input is not skipped
+	others := []silo.DataRow{{"ID": "6", "EMAIL": "f"}, {"ID": "7", "EMAIL": "g"}}
+	require.NoError(t, silo.NewDriver(backend, nil, options...).Scan(silo.NewDataRowReaderInMemory(others)))
+
+	metadata, err = backend.Metadata()
+
+	require.NoError(t, err)
+
+	last, _ = metadata.LastScan()
+
+	assert.Equal(t, 0, last.Skipped)
+	assert.Equal(t, 2, last.Rows)
+	assert.Len(t, dumpEntities(t, backend), 7)
+}
+
+func TestDumpChanged(t *testing.T) {
+	t.Parallel()
+
+	backend := silo.NewBackendInMemory()
+	ctx := context.Background()
+
+	scan := func(rows ...silo.DataRow) {
+		driver := silo.NewDriver(backend, nil, silo.WithKeys([]string{"ID", "EMAIL"}))
+		require.NoError(t, driver.Scan(silo.NewDataRowReaderInMemory(rows)))
+	}
+
+	dumpChanged := func() map[string]silo.EntitySummary {
+		observer := &observeInMemory{entities: []silo.EntitySummary{}}
+		driver := silo.NewDriver(backend, newDumpToMemory())
+		require.NoError(t, driver.DumpChanged(ctx, observer))
+
+		entities := map[string]silo.EntitySummary{}
+		for _, entity := range observer.entities {
+			entities[entity.UUID] = entity
+		}
+
+		return entities
+	}
+
+	scan(silo.DataRow{"ID": "1", "EMAIL": "a"}, silo.DataRow{"ID": "2", "EMAIL": "b"})
+
+	first := dumpChanged()
+	require.Len(t, first, 2)
+
+	for _, entity := range first {
+		assert.Empty(t, entity.Replaces)
+	}
+
+	assert.Empty(t, dumpChanged(), "nothing changed since the last dump")
+
+	scan(silo.DataRow{"ID": "2", "EMAIL": "a"})
+
+	merged := dumpChanged()
+	require.Len(t, merged, 1)
+
+	previous := make([]string, 0, len(first))
+	for uuid := range first {
+		previous = append(previous, uuid)
+	}
+
+	for _, entity := range merged {
+		assert.ElementsMatch(t, previous, entity.Replaces)
+	}
+}
+
+func TestDumpChangedFiltered(t *testing.T) {
+	t.Parallel()
+
+	rows := []silo.DataRow{
+		{"ID1": 1, "ID2": "1"},
+		{"ID1": 2, "ID2": "2"},
+		{"ID1": 2, "ID2": "3"},
+		{"ID1": 3, "ID2": "4"},
+		{"ID1": 3, "ID2": "5"},
+	}
+
+	backend := &countingTracker{BackendInMemory: silo.NewBackendInMemory(), untouches: 0}
+	options := []silo.Option{
+		silo.WithKeys([]string{"ID1", "ID2"}),
+		silo.WithStatuses([]silo.Status{silo.StatusEntityInconsistent}),
+	}
+
+	require.NoError(t, silo.NewDriver(backend, nil, options...).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	writer := newDumpToMemory()
+	require.NoError(t, silo.NewDriver(backend, writer, options...).DumpChanged(context.Background()))
+
+	assert.Len(t, writer.entities, 2)
+	assert.Equal(t, 1, backend.untouches, "changes are forgotten at once")
+
+	// the consistent entity was filtered out, it is not dumped again
+	changes, err := backend.Changes()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDumpChangedNotTracked(t *testing.T) {
+	t.Parallel()
+
+	driver := silo.NewDriver(untrackedBackend{silo.NewBackendInMemory()}, nil)
+
+	require.ErrorIs(t, driver.DumpChanged(context.Background()), silo.ErrChangesNotTracked)
+}
+
+// countingTracker counts the calls to Untouch of the wrapped backend.
+type countingTracker struct {
+	*silo.BackendInMemory
+	untouches int
+}
+
+func (b *countingTracker) Untouch(changes ...silo.Change) error {
+	b.untouches++
+
+	return b.BackendInMemory.Untouch(changes...) //nolint:wrapcheck
+}
+
+// untrackedBackend hides the change tracking of the wrapped backend.
+type untrackedBackend struct {
+	silo.Backend
 }
@@ -26,6 +26,8 @@
 	ErrReadingNextInput     = errors.New("error while reading next input")
 	ErrPersistingData       = errors.New("error while persisting data")
 	ErrReadingPersistedData = errors.New("error while reading persisted data")
+	ErrInterrupted          = errors.New("interrupted")
+	ErrChangesNotTracked    = errors.New("backend does not track changes")
 )
 
 type ConfigScanAliasIsNotIncludedError struct {
@@ -35,3 +37,63 @@ type ConfigScanAliasIsNotIncludedError struct {
 func (e *ConfigScanAliasIsNotIncludedError) Error() string {
 	return fmt.Sprintf("configuration error : alias [%s] is not included", e.alias)
 }
+
+type ConfigDumpUnknownStatusError struct {
+	status Status
+}
+
+func (e *ConfigDumpUnknownStatusError) Error() string {
+	return fmt.Sprintf("configuration error : status [%s] is unknown", e.status)
+}
+
+type ConfigKeyIsMandatoryAndOptionalError struct {
+	key string
+}
+
+func (e *ConfigKeyIsMandatoryAndOptionalError) Error() string {
+	return fmt.Sprintf("configuration error : key [%s] cannot be both mandatory and optional", e.key)
+}
+
+type ConfigInvalidCardinalityError struct {
+	key         string
+	cardinality Cardinality
+}
+
+func (e *ConfigInvalidCardinalityError) Error() string {
+	return fmt.Sprintf("configuration error : cardinality [%d:%d] of key [%s] is invalid",
+		e.cardinality.Min, e.cardinality.Max, e.key)
+}
+
+type ConfigRuleStatusIsReservedError struct {
+	status Status
+}
+
+func (e *ConfigRuleStatusIsReservedError) Error() string {
+	return fmt.Sprintf("configuration error : status [%s] is reserved and cannot be used by a rule", e.status)
+}
+
+type ConfigInvalidFuzzyMatchingError struct {
+	key string
+}
+
+func (e *ConfigInvalidFuzzyMatchingError) Error() string {
+	return fmt.Sprintf("configuration error : fuzzy matching of key [%s] needs a blocking function, "+
+		"a similarity function and a threshold in ]0,1]", e.key)
+}
+
+type ConfigCompositeWithoutPartError struct {
+	key string
+}
+
+func (e *ConfigCompositeWithoutPartError) Error() string {
+	return fmt.Sprintf("configuration error : composite key [%s] has no part", e.key)
+}
+
+type ConfigUnknownMergePolicyError struct {
+	key    string
+	policy MergePolicy
+}
+
+func (e *ConfigUnknownMergePolicyError) Error() string {
+	return fmt.Sprintf("configuration error : merge policy [%s] of attribute [%s] is unknown", e.policy, e.key)
+}
@@ -57,6 +57,20 @@ func toStringRepresentationBuffered(value any, stringbuffer *strings.Builder) {
 			toStringRepresentationBuffered(value, stringbuffer)
 		}
 
+		stringbuffer.WriteByte(')')
+	case CompositeValue:
+		stringbuffer.WriteString("composite(")
+		stringbuffer.WriteString(string(tvalue))
+		stringbuffer.WriteByte(')')
+	case AttributeValue:
+		stringbuffer.WriteString("attribute(")
+		stringbuffer.WriteString(strconv.FormatInt(tvalue.Sequence, 10))
+		stringbuffer.WriteByte(',')
+		toStringRepresentationBuffered(tvalue.Value, stringbuffer)
+		stringbuffer.WriteByte(')')
+	case SoftValue:
+		stringbuffer.WriteString("soft(")
+		toStringRepresentationBuffered(tvalue.Value, stringbuffer)
 		stringbuffer.WriteByte(')')
 	case nil:
 		stringbuffer.WriteString("nil(nil)")
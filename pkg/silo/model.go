@@ -20,6 +20,7 @@
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -36,6 +37,46 @@ type DataLink struct {
 	E2 DataNode
 }
 
+// CompositeValue is the value of a node made of several columns, encoded as a JSON object with sorted keys.
+type CompositeValue string
+
+// SoftValue is the value of a node reached through a soft link, a link found by similarity instead of equality.
+type SoftValue struct {
+	Value any
+}
+
+func init() { //nolint:gochecknoinits
+	gob.Register(CompositeValue(""))
+	gob.Register(SoftValue{}) //nolint:exhaustruct
+}
+
+// NewCompositeValue encodes the values of the given parts, it returns false if one of the parts is missing or null.
+func NewCompositeValue(datarow DataRow, parts []string) (CompositeValue, bool) {
+	values := make(map[string]any, len(parts))
+
+	for _, part := range parts {
+		value, exist := datarow[part]
+		if !exist || value == nil {
+			return "", false
+		}
+
+		values[part] = value
+	}
+
+	// encoding/json sorts map keys, so the same values always give the same composite value
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", false
+	}
+
+	return CompositeValue(encoded), true
+}
+
+// MarshalJSON renders the composite value as a JSON object.
+func (c CompositeValue) MarshalJSON() ([]byte, error) {
+	return []byte(c), nil
+}
+
 func DecodeDataNode(data []byte) (DataNode, error) {
 	var result DataNode
 
@@ -71,3 +112,37 @@ func (n DataNode) String() string {
 
 	return result.String()
 }
+
+// Soft returns the node marked as the target of a soft link.
+func (n DataNode) Soft() DataNode {
+	if n.IsSoft() {
+		return n
+	}
+
+	return DataNode{Key: n.Key, Data: SoftValue{Value: n.Data}}
+}
+
+// IsSoft returns true if the node is marked as the target of a soft link.
+func (n DataNode) IsSoft() bool {
+	_, soft := n.Data.(SoftValue)
+
+	return soft
+}
+
+// Hard returns the node without soft link mark.
+func (n DataNode) Hard() DataNode {
+	if value, soft := n.Data.(SoftValue); soft {
+		return DataNode{Key: n.Key, Data: value.Value}
+	}
+
+	return n
+}
+
+// Rename returns the node with its key replaced by its alias, soft link and attribute marks are preserved.
+func (n DataNode) Rename(aliases map[string]string) DataNode {
+	if alias, exist := aliases[n.Key]; exist {
+		return DataNode{Key: alias, Data: n.Data}
+	}
+
+	return n
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"encoding/gob"
+	"reflect"
+	"sort"
+)
+
+type MergePolicy string
+
+const (
+	MergeFirst MergePolicy = "first"
+	MergeLast  MergePolicy = "last"
+	MergeAll   MergePolicy = "all"
+	MergeMax   MergePolicy = "max"
+)
+
+func (p MergePolicy) valid() bool {
+	switch p {
+	case MergeFirst, MergeLast, MergeAll, MergeMax:
+		return true
+	default:
+		return false
+	}
+}
+
+// AttributeValue is a value of an attribute column, with the sequence of the scan that stored it. A node holding an
+// attribute value never links nodes together.
+type AttributeValue struct {
+	Sequence int64
+	Value    any
+}
+
+func init() { //nolint:gochecknoinits
+	gob.Register(AttributeValue{}) //nolint:exhaustruct
+}
+
+// NewAttributeNode returns the node that stores value of the attribute column key, or false if value cannot be stored.
+func NewAttributeNode(key string, value any, sequence int64) (DataNode, bool) {
+	// nodes are used as map keys, so the value must be comparable (JSON arrays and objects are not)
+	if value == nil || !reflect.TypeOf(value).Comparable() {
+		return DataNode{}, false
+	}
+
+	return DataNode{Key: key, Data: AttributeValue{Sequence: sequence, Value: value}}, true
+}
+
+// IsAttribute returns true if the node carries an attribute value.
+func (n DataNode) IsAttribute() bool {
+	_, attribute := n.Data.(AttributeValue)
+
+	return attribute
+}
+
+// attributes collects the attribute values found while dumping an entity.
+type attributes map[string][]AttributeValue
+
+func (a attributes) add(node DataNode) {
+	if value, ok := node.Data.(AttributeValue); ok {
+		a[node.Key] = append(a[node.Key], value)
+	}
+}
+
+func (a attributes) merge(policies map[string]MergePolicy) map[string]any {
+	if len(a) == 0 {
+		return nil
+	}
+
+	result := make(map[string]any, len(a))
+
+	for key, values := range a {
+		sort.SliceStable(values, func(i, j int) bool { return values[i].Sequence < values[j].Sequence })
+
+		switch policies[key] {
+		case MergeFirst:
+			result[key] = values[0].Value
+		case MergeLast:
+			result[key] = values[len(values)-1].Value
+		case MergeMax:
+			result[key] = maxValue(values)
+		case MergeAll:
+			fallthrough
+		default:
+			result[key] = distinctValues(values)
+		}
+	}
+
+	return result
+}
+
+func distinctValues(values []AttributeValue) []any {
+	seen := make(map[any]bool, len(values))
+	result := make([]any, 0, len(values))
+
+	for _, value := range values {
+		if !seen[value.Value] {
+			seen[value.Value] = true
+			result = append(result, value.Value)
+		}
+	}
+
+	return result
+}
+
+func maxValue(values []AttributeValue) any {
+	result := values[0].Value
+
+	for _, value := range values[1:] {
+		if less(result, value.Value) {
+			result = value.Value
+		}
+	}
+
+	return result
+}
+
+// less compares numbers by value and any other values by their string representation.
+func less(a, b any) bool {
+	numberA, isNumberA := toFloat(a)
+	numberB, isNumberB := toFloat(b)
+
+	if isNumberA && isNumberB {
+		return numberA < numberB
+	}
+
+	return DataNode{Data: a}.String() < DataNode{Data: b}.String() //nolint:exhaustruct
+}
+
+func toFloat(value any) (float64, bool) {
+	reflected := reflect.ValueOf(value)
+
+	switch reflected.Kind() { //nolint:exhaustive
+	case reflect.Float32, reflect.Float64:
+		return reflected.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(reflected.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(reflected.Uint()), true
+	default:
+		return 0, false
+	}
+}
@@ -33,19 +33,49 @@
 	StatusEntityEmpty        Status = "empty"
 )
 
+func (s Status) builtin() bool {
+	switch s {
+	case StatusEntityComplete, StatusEntityConsistent, StatusEntityInconsistent, StatusEntityEmpty:
+		return true
+	default:
+		return false
+	}
+}
+
+type EntitySummary struct {
+	UUID       string
+	Status     Status
+	Counts     map[string]int
+	Violations []Violation
+	Attributes map[string]any
+	// Replaces lists the ids of the entities of the previous dump of changes that contained one of its nodes.
+	Replaces []string
+	// Removed is true for an entity with no node, that removes the entities it replaces.
+	Removed bool
+}
+
 type Entity struct {
-	include []string
-	nodes   map[DataNode]int
-	counts  map[string]int
-	uuid    string
+	include    []string
+	membership *membership
+	nodes      map[DataNode]int
+	counts     map[string]int
+	attributes attributes
+	uuid       string
 }
 
+// NewEntity creates an entity where all included keys are mandatory.
 func NewEntity(include []string, nodes ...DataNode) Entity {
+	return newEntity(include, newMembership(include), nodes...)
+}
+
+func newEntity(include []string, membership *membership, nodes ...DataNode) Entity {
 	entity := Entity{
-		include: include,
-		nodes:   make(map[DataNode]int, defaultEntitySize),
-		counts:  make(map[string]int, defaultEntitySize),
-		uuid:    uuid.NewString(),
+		include:    include,
+		membership: membership,
+		nodes:      make(map[DataNode]int, defaultEntitySize),
+		counts:     make(map[string]int, defaultEntitySize),
+		attributes: attributes{},
+		uuid:       uuid.NewString(),
 	}
 	for _, node := range nodes {
 		entity.Append(node)
@@ -54,7 +84,21 @@ func NewEntity(include []string, nodes ...DataNode) Entity {
 	return entity
 }
 
+// count adds a node that is not part of the entity to its counts, without recording the node : the nodes of an entity
+// being dumped are recorded by its buffer.
+func (s Entity) count(node DataNode) {
+	s.counts[node.Key]++
+}
+
+// Append adds node to the entity and returns true if the node was not already part of the entity,
+// attribute nodes are collected but always return false as they are not connected to other nodes.
 func (s Entity) Append(node DataNode) bool {
+	if node.IsAttribute() {
+		s.attributes.add(node)
+
+		return false
+	}
+
 	count, gotNode := s.nodes[node]
 	if gotNode {
 		s.nodes[node] = count + 1
@@ -76,10 +120,12 @@ func (s Entity) UUID() string {
 	return s.uuid
 }
 
-func (s Entity) Finalize() (Status, map[string]int) {
-	msg := log.Info().Str("status", string(StatusEntityConsistent))
+// Attributes returns the values of attributes columns merged with given policies (default policy is MergeAll).
+func (s Entity) Attributes(policies map[string]MergePolicy) map[string]any {
+	return s.attributes.merge(policies)
+}
 
-	status := StatusEntityConsistent
+func (s Entity) Finalize() (Status, map[string]int, []Violation) {
 	counts := s.counts
 
 	if len(s.include) > 0 {
@@ -91,30 +137,29 @@ func (s Entity) Finalize() (Status, map[string]int) {
 		}
 	}
 
-	if len(counts) == len(s.include) && len(s.include) > 0 {
-		msg.Str("status", string(StatusEntityComplete))
-		status = StatusEntityComplete
-	} else if len(counts) == 0 {
-		msg.Str("status", string(StatusEntityEmpty))
-		status = StatusEntityEmpty
-	}
-
-	for _, count := range counts {
-		if count > 1 {
-			msg = log.Warn().Str("status", string(StatusEntityInconsistent))
-			status = StatusEntityInconsistent
+	status, violations := s.membership.evaluate(counts)
 
-			break
-		}
+	msg := log.Info()
+	if status == StatusEntityInconsistent {
+		msg = log.Warn()
 	}
 
-	msg.Str("uuid", s.UUID())
+	msg.Str("status", string(status)).Str("uuid", s.UUID())
 
 	for id, count := range counts {
 		msg.Int("count-"+id, count)
 	}
 
+	if len(violations) > 0 {
+		reasons := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			reasons = append(reasons, violation.String())
+		}
+
+		msg.Strs("violations", reasons)
+	}
+
 	msg.Msg("entity identified")
 
-	return status, counts
+	return status, counts, violations
 }
@@ -0,0 +1,130 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"sort"
+	"time"
+)
+
+// Metadata describes what was scanned into a silo.
+type Metadata struct {
+	Scans       []ScanRecord          `json:"scans"`
+	Checkpoints map[string]Checkpoint `json:"checkpoints,omitempty"`
+	// Compacted is true once redundant self references were dropped, a value that a row contributed on its own cannot
+	// be told apart from the other values anymore.
+	Compacted bool `json:"compacted,omitempty"`
+}
+
+// Checkpoint is the position reached in a source, rows before this position are ingested.
+type Checkpoint struct {
+	Rows     int       `json:"rows"`
+	Sequence int64     `json:"sequence"`
+	Time     time.Time `json:"time"`
+	Complete bool      `json:"complete,omitempty"`
+}
+
+// ScanRecord describes a single scan : its source, the SILO version used, counters and options.
+type ScanRecord struct {
+	Time        time.Time           `json:"time"`
+	Source      string              `json:"source,omitempty"`
+	Version     string              `json:"version,omitempty"`
+	Rows        int                 `json:"rows"`
+	Skipped     int                 `json:"skipped,omitempty"`
+	Links       int                 `json:"links"`
+	Include     []string            `json:"include,omitempty"`
+	Aliases     map[string]string   `json:"aliases,omitempty"`
+	Composites  map[string][]string `json:"composites,omitempty"`
+	Attributes  []string            `json:"attributes,omitempty"`
+	Fuzzy       []string            `json:"fuzzy,omitempty"`
+	Interrupted bool                `json:"interrupted,omitempty"`
+}
+
+// LastScan returns the most recent scan record, it returns false if the silo was never scanned.
+func (m Metadata) LastScan() (ScanRecord, bool) {
+	if len(m.Scans) == 0 {
+		return ScanRecord{}, false //nolint:exhaustruct
+	}
+
+	return m.Scans[len(m.Scans)-1], true
+}
+
+// Checkpoint returns the last checkpoint saved for source.
+func (m Metadata) Checkpoint(source string) (Checkpoint, bool) {
+	checkpoint, ok := m.Checkpoints[source]
+
+	return checkpoint, ok
+}
+
+func (m *Metadata) setCheckpoint(source string, checkpoint Checkpoint) {
+	if m.Checkpoints == nil {
+		m.Checkpoints = map[string]Checkpoint{}
+	}
+
+	m.Checkpoints[source] = checkpoint
+}
+
+// Append adds the scans of other, and its checkpoints that are further in their source than the checkpoints of m.
+// The silo is compacted if other is.
+func (m *Metadata) Append(other Metadata) {
+	m.Scans = append(m.Scans, other.Scans...)
+	m.Compacted = m.Compacted || other.Compacted
+
+	for source, checkpoint := range other.Checkpoints {
+		if current, ok := m.Checkpoint(source); ok && !checkpoint.further(current) {
+			continue
+		}
+
+		m.setCheckpoint(source, checkpoint)
+	}
+}
+
+// further returns true if the checkpoint is further in its source than other.
+func (c Checkpoint) further(other Checkpoint) bool {
+	return c.Rows > other.Rows || (c.Rows == other.Rows && c.Complete && !other.Complete)
+}
+
+// InconsistentAliases returns the sorted list of fields renamed by a previous scan and renamed differently
+// (or not renamed at all) by the given scan.
+func (m Metadata) InconsistentAliases(record ScanRecord) []string {
+	inconsistent := map[string]bool{}
+
+	for _, previous := range m.Scans {
+		for field, alias := range previous.Aliases {
+			if record.Aliases[field] != alias {
+				inconsistent[field] = true
+			}
+		}
+
+		for field, alias := range record.Aliases {
+			if previous.Aliases[field] != alias {
+				inconsistent[field] = true
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(inconsistent))
+
+	for field := range inconsistent {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
@@ -0,0 +1,166 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+package silo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Unbounded can be used as the maximum of a Cardinality to accept any number of values.
+const Unbounded = -1
+
+// Cardinality bounds the number of distinct values of a key inside an entity.
+type Cardinality struct {
+	Min int
+	Max int
+}
+
+func (c Cardinality) accept(count int) bool {
+	return count >= c.Min && (c.Max == Unbounded || count <= c.Max)
+}
+
+func (c Cardinality) valid() bool {
+	return c.Min >= 0 && (c.Max == Unbounded || c.Max >= c.Min)
+}
+
+func (c Cardinality) String() string {
+	if c.Max == Unbounded {
+		return fmt.Sprintf("at least %d", c.Min)
+	}
+
+	return fmt.Sprintf("between %d and %d", c.Min, c.Max)
+}
+
+type Constraint struct {
+	Key string
+	Cardinality
+}
+
+// Rule gives its status to an entity when all its constraints are satisfied.
+type Rule struct {
+	Status      Status
+	Constraints []Constraint
+}
+
+// Violation describes a constraint of a rule that an entity did not satisfy.
+type Violation struct {
+	Rule  Status
+	Key   string
+	Count int
+	Cardinality
+}
+
+func (v Violation) String() string {
+	return "rule [" + string(v.Rule) + "] key [" + v.Key + "] has " + strconv.Itoa(v.Count) +
+		" value(s), expected " + v.Cardinality.String()
+}
+
+type membership struct {
+	mandatory     []string
+	cardinalities map[string]Cardinality
+	rules         []Rule
+}
+
+func newMembership(mandatory []string) *membership {
+	return &membership{
+		mandatory:     mandatory,
+		cardinalities: map[string]Cardinality{},
+		rules:         []Rule{},
+	}
+}
+
+func (m *membership) cardinality(key string, mandatory bool) Cardinality {
+	if cardinality, exist := m.cardinalities[key]; exist {
+		return cardinality
+	}
+
+	if mandatory {
+		return Cardinality{Min: 1, Max: 1}
+	}
+
+	return Cardinality{Min: 0, Max: 1}
+}
+
+func (m *membership) evaluate(counts map[string]int) (Status, []Violation) {
+	violations := []Violation{}
+
+	// custom rules are evaluated first, in declaration order, the first satisfied rule gives its status
+	for _, rule := range m.rules {
+		failed := []Violation{}
+
+		for _, constraint := range rule.Constraints {
+			if count := counts[constraint.Key]; !constraint.accept(count) {
+				failed = append(failed, Violation{
+					Rule:        rule.Status,
+					Key:         constraint.Key,
+					Count:       count,
+					Cardinality: constraint.Cardinality,
+				})
+			}
+		}
+
+		if len(failed) == 0 {
+			return rule.Status, violations
+		}
+
+		violations = append(violations, failed...)
+	}
+
+	if len(counts) == 0 {
+		return StatusEntityEmpty, violations
+	}
+
+	consistent := true
+
+	for key, count := range counts {
+		if cardinality := m.cardinality(key, false); cardinality.Max != Unbounded && count > cardinality.Max {
+			violations = append(violations, Violation{
+				Rule:        StatusEntityConsistent,
+				Key:         key,
+				Count:       count,
+				Cardinality: cardinality,
+			})
+			consistent = false
+		}
+	}
+
+	if !consistent {
+		return StatusEntityInconsistent, violations
+	}
+
+	complete := len(m.mandatory) > 0
+
+	for _, key := range m.mandatory {
+		if cardinality := m.cardinality(key, true); !cardinality.accept(counts[key]) {
+			violations = append(violations, Violation{
+				Rule:        StatusEntityComplete,
+				Key:         key,
+				Count:       counts[key],
+				Cardinality: cardinality,
+			})
+			complete = false
+		}
+	}
+
+	if complete {
+		return StatusEntityComplete, violations
+	}
+
+	return StatusEntityConsistent, violations
+}
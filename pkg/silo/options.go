@@ -43,11 +43,7 @@ func Alias(key, alias string) Option { //nolint:ireturn
 
 func Include(key string) Option { //nolint:ireturn
 	applier := func(cfg *config) error {
-		if _, exist := cfg.include[key]; !exist {
-			cfg.includeList = append(cfg.includeList, key)
-		}
-
-		cfg.include[key] = true
+		cfg.includeKey(key)
 
 		return nil
 	}
@@ -70,11 +66,51 @@ func WithAliases(aliases map[string]string) Option { //nolint:ireturn
 func WithKeys(keys []string) Option { //nolint:ireturn
 	applier := func(cfg *config) error {
 		for _, key := range keys {
-			if _, exist := cfg.include[key]; !exist {
-				cfg.includeList = append(cfg.includeList, key)
+			cfg.includeKey(key)
+		}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+func WithStatuses(statuses []Status) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		for _, status := range statuses {
+			cfg.statuses[status] = true
+		}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithMandatoryKeys declares keys that must be present for an entity to be complete, these keys are also included.
+func WithMandatoryKeys(keys []string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		for _, key := range keys {
+			if _, exist := cfg.mandatory[key]; !exist {
+				cfg.mandatoryList = append(cfg.mandatoryList, key)
 			}
 
-			cfg.include[key] = true
+			cfg.mandatory[key] = true
+			cfg.includeKey(key)
+		}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithOptionalKeys declares keys that are not required for an entity to be complete, these keys are also included.
+func WithOptionalKeys(keys []string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		for _, key := range keys {
+			cfg.optional[key] = true
+			cfg.includeKey(key)
 		}
 
 		return nil
@@ -82,3 +118,138 @@ func WithKeys(keys []string) Option { //nolint:ireturn
 
 	return option(applier)
 }
+
+// WithCardinality overrides the expected number of distinct values of key in an entity (default is at most 1).
+func WithCardinality(key string, min, max int) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.cardinalities[key] = Cardinality{Min: min, Max: max}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithRule declares a custom status given to entities satisfying all constraints, rules are evaluated in order.
+func WithRule(status Status, constraints ...Constraint) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.rules = append(cfg.rules, Rule{Status: status, Constraints: constraints})
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithFuzzyMatching adds soft links at the end of a scan between values of key that share a block
+// and have a similarity greater or equal to threshold.
+func WithFuzzyMatching(
+	key string,
+	blocking func(value string) []string,
+	similarity func(a, b string) float64,
+	threshold float64,
+) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.fuzzy[key] = fuzzyMatcher{blocking: blocking, similarity: similarity, threshold: threshold}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithSoftLinks sets if soft links are followed when dumping entities (default is true).
+func WithSoftLinks(follow bool) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.softLinks = follow
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithComposite declares a key whose value combines the values of several columns,
+// these columns are then hidden and do not create links on their own.
+func WithComposite(key string, parts []string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.composites = append(cfg.composites, composite{key: key, parts: parts})
+
+		for _, part := range parts {
+			cfg.hidden[part] = true
+		}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithAttributes declares columns stored as attributes of the nodes of each row, attributes never create links.
+func WithAttributes(keys []string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		for _, key := range keys {
+			cfg.attributes[key] = true
+		}
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithMergePolicy sets how the values of an attribute are merged when dumping an entity (default is MergeAll).
+func WithMergePolicy(key string, policy MergePolicy) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.policies[key] = policy
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithSource names the scanned input in the metadata of the silo.
+func WithSource(source string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.source = source
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithVersion records the SILO version used to scan in the metadata of the silo.
+func WithVersion(version string) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.version = version
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithCheckpoint sets the number of rows between two checkpoints saved in the metadata of the silo,
+// 0 disables checkpoints (default is DefaultCheckpointInterval).
+func WithCheckpoint(interval int) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.checkpoint = interval
+
+		return nil
+	}
+
+	return option(applier)
+}
+
+// WithResume skips the rows of the source already ingested according to its last checkpoint.
+func WithResume(resume bool) Option { //nolint:ireturn
+	applier := func(cfg *config) error {
+		cfg.resume = resume
+
+		return nil
+	}
+
+	return option(applier)
+}
@@ -0,0 +1,345 @@
+// Copyright (C) 2024 CGI France
+//
+// This file is part of SILO.
+//
+// SILO is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// SILO is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with SILO.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package silotest provides a conformance suite that any silo.Backend must pass, and helpers to assert dumped
+// entities.
+package silotest
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/cgi-fr/silo/pkg/silo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new empty backend, it is called once per test of the suite and the backend is closed by the suite.
+type Factory func(t *testing.T) silo.Backend
+
+type config struct {
+	isolation bool
+}
+
+// Option configures the conformance suite.
+type Option func(*config)
+
+// WithoutSnapshotIsolation skips the test of snapshot isolation, for backends whose snapshots read live data.
+func WithoutSnapshotIsolation() Option {
+	return func(c *config) {
+		c.isolation = false
+	}
+}
+
+// TestBackend runs the conformance suite against backends returned by factory.
+func TestBackend(t *testing.T, factory Factory, options ...Option) {
+	t.Helper()
+
+	config := &config{isolation: true}
+	for _, option := range options {
+		option(config)
+	}
+
+	tests := map[string]func(t *testing.T, backend silo.Backend){
+		"links":          testLinks,
+		"symmetric":      testSymmetricLinks,
+		"self links":     testSelfLinks,
+		"pull all":       testPullAll,
+		"next exhausted": testNextExhausted,
+		"entities":       testEntities,
+	}
+
+	if config.isolation {
+		tests["snapshot isolation"] = testSnapshotIsolation
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			backend := factory(t)
+			defer backend.Close()
+
+			test(t, backend)
+		})
+	}
+}
+
+// Entities dumps backend and returns the entities as sorted groups of nodes, formatted as key=type(value).
+func Entities(t *testing.T, backend silo.Backend, options ...silo.Option) [][]string {
+	t.Helper()
+
+	writer := &entitiesWriter{entities: map[string][]string{}}
+	require.NoError(t, silo.NewDriver(backend, writer, options...).Dump())
+
+	entities := make([][]string, 0, len(writer.entities))
+
+	for _, nodes := range writer.entities {
+		sort.Strings(nodes)
+
+		entities = append(entities, nodes)
+	}
+
+	sort.Slice(entities, func(i, j int) bool { return entities[i][0] < entities[j][0] })
+
+	return entities
+}
+
+type entitiesWriter struct {
+	entities map[string][]string
+}
+
+func (w *entitiesWriter) Write(node silo.DataNode, entity silo.EntitySummary) error {
+	w.entities[entity.UUID] = append(w.entities[entity.UUID], node.String())
+
+	return nil
+}
+
+func (w *entitiesWriter) Close() error {
+	return nil
+}
+
+// drain pulls every node of the snapshot and returns the nodes returned by Next.
+func drain(t *testing.T, snapshot silo.Snapshot) []silo.DataNode {
+	t.Helper()
+
+	nodes := []silo.DataNode{}
+
+	for {
+		node, hasNext, err := snapshot.Next()
+		require.NoError(t, err)
+
+		if !hasNext {
+			return nodes
+		}
+
+		nodes = append(nodes, node)
+
+		_, err = snapshot.PullAll(node)
+		require.NoError(t, err)
+	}
+}
+
+func testLinks(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	node, other := silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(node, other))
+	require.NoError(t, backend.Store(node, other))
+
+	links, err := backend.Get(node)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{other}, links, "a link is stored once")
+
+	links, err = backend.Get(silo.DataNode{Key: "ID", Data: 1})
+
+	require.NoError(t, err)
+	assert.Empty(t, links, "nodes with values of different types are different")
+
+	require.NoError(t, backend.Remove(node, other))
+	require.NoError(t, backend.Remove(node, other), "removing a missing link is not an error")
+
+	links, err = backend.Get(node)
+
+	require.NoError(t, err)
+	assert.Empty(t, links)
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	assert.Empty(t, drain(t, snapshot), "a node without links is deleted")
+
+	require.NoError(t, backend.Store(node, other))
+	require.NoError(t, backend.Delete(node))
+	require.NoError(t, backend.Delete(node), "deleting a missing node is not an error")
+
+	links, err = backend.Get(node)
+
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
+
+func testSymmetricLinks(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	rows := []silo.DataRow{
+		{"ID": "1", "EMAIL": "a", "PHONE": "0601"},
+		{"ID": "2", "EMAIL": "a"},
+	}
+
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	nodes := []silo.DataNode{
+		{Key: "ID", Data: "1"}, {Key: "ID", Data: "2"}, {Key: "EMAIL", Data: "a"}, {Key: "PHONE", Data: "0601"},
+	}
+
+	for _, node := range nodes {
+		links, err := backend.Get(node)
+		require.NoError(t, err)
+		require.NotEmpty(t, links, node.String())
+
+		for _, link := range links {
+			reverse, err := backend.Get(link)
+			require.NoError(t, err)
+			assert.Contains(t, reverse, node, "link %v -> %v has no reverse link", node, link)
+		}
+	}
+}
+
+func testSelfLinks(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	node := silo.DataNode{Key: "ID", Data: "1"}
+
+	require.NoError(t, backend.Store(node, node))
+
+	links, err := backend.Get(node)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{node}, links)
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	next, hasNext, err := snapshot.Next()
+
+	require.NoError(t, err)
+	require.True(t, hasNext)
+	assert.Equal(t, node, next)
+
+	links, err = snapshot.PullAll(node)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{node}, links)
+}
+
+func testPullAll(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	id, email := silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	links, err := snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{email}, links)
+
+	links, err = snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.Empty(t, links, "a node is pulled once")
+
+	links, err = snapshot.PullAll(silo.DataNode{Key: "ID", Data: "unknown"})
+
+	require.NoError(t, err)
+	assert.Empty(t, links, "an unknown node has no links")
+
+	assert.Equal(t, []silo.DataNode{email}, drain(t, snapshot), "a pulled node is never returned by Next")
+
+	// pulling from a snapshot does not modify the backend
+	links, err = backend.Get(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, []silo.DataNode{email}, links)
+}
+
+func testNextExhausted(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	empty := backend.Snapshot()
+
+	_, hasNext, err := empty.Next()
+
+	require.NoError(t, err)
+	assert.False(t, hasNext, "an empty backend has no nodes")
+	require.NoError(t, empty.Close())
+
+	for _, value := range []string{"1", "2", "3"} {
+		node := silo.DataNode{Key: "ID", Data: value}
+		require.NoError(t, backend.Store(node, node))
+	}
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	assert.Len(t, drain(t, snapshot), 3)
+
+	for i := 0; i < 2; i++ {
+		_, hasNext, err := snapshot.Next()
+
+		require.NoError(t, err)
+		assert.False(t, hasNext, "an exhausted snapshot stays exhausted")
+	}
+}
+
+func testSnapshotIsolation(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	id, email := silo.DataNode{Key: "ID", Data: "1"}, silo.DataNode{Key: "EMAIL", Data: "a"}
+	phone := silo.DataNode{Key: "PHONE", Data: "0601"}
+
+	require.NoError(t, backend.Store(id, email))
+	require.NoError(t, backend.Store(email, id))
+
+	snapshot := backend.Snapshot()
+	defer snapshot.Close()
+
+	// written after the snapshot, not visible through it
+	require.NoError(t, backend.Store(id, phone))
+	require.NoError(t, backend.Store(phone, id))
+	require.NoError(t, backend.Delete(email))
+
+	nodes := drain(t, snapshot)
+
+	assert.ElementsMatch(t, []silo.DataNode{id, email}, nodes)
+
+	snapshot = backend.Snapshot()
+	defer snapshot.Close()
+
+	links, err := snapshot.PullAll(id)
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []silo.DataNode{email, phone}, links, "a new snapshot sees all writes")
+}
+
+func testEntities(t *testing.T, backend silo.Backend) {
+	t.Helper()
+
+	rows := []silo.DataRow{
+		{"ID": 1, "EMAIL": "a"},
+		{"ID": 2, "EMAIL": "b"},
+		{"ID": 2, "EMAIL": "c"},
+		{"ID": 3},
+		{"EMAIL": "d", "PHONE": nil},
+	}
+
+	require.NoError(t, silo.NewDriver(backend, nil).Scan(silo.NewDataRowReaderInMemory(rows)))
+
+	assert.Equal(t, [][]string{
+		{"EMAIL=string(a)", "ID=number(1)"},
+		{"EMAIL=string(b)", "EMAIL=string(c)", "ID=number(2)"},
+		{"EMAIL=string(d)"},
+		{"ID=number(3)"},
+	}, Entities(t, backend))
+}